@@ -2,27 +2,56 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"html"
 	"net/http"
 	"net/url"
-	"text/template"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 )
 
 const (
-	hackerNewsItemID = "https://news.ycombinator.com/item?id="
-	algoliaSearchURL = "https://hn.algolia.com/api/v1/search_by_date?"
+	hackerNewsItemID  = "https://news.ycombinator.com/item?id="
+	hackerNewsUserID  = "https://news.ycombinator.com/user?id="
+	waybackMachineURL = "https://web.archive.org/web/"
+	algoliaSearchURL  = "https://hn.algolia.com/api/v1/search_by_date?"
+	algoliaItemsURL   = "https://hn.algolia.com/api/v1/items/"
 )
 
 var algoliaClient = http.Client{
 	Timeout: 10 * time.Second,
 }
 
+// itemCacheMaxEntries is kept small since each entry is a whole
+// discussion tree, which runs to megabytes for a big thread.
+const itemCacheMaxEntries = 100
+
+var itemCache = newTTLCache("items", 5*time.Minute, itemCacheMaxEntries)
+
+// bracketedPlaceholder matches a title_format placeholder in brackets,
+// along with any whitespace before it.
+var bracketedPlaceholder = regexp.MustCompile(`\s*(?:\(\s*(\{\w+\})\s*\)|\[\s*(\{\w+\})\s*\])`)
+
 type AlgoliaSearchResponse struct {
 	Hits []AlgoliaSearchHit
+
+	// Stale is set when Algolia was unavailable and these are the results
+	// of an earlier identical search.
+	Stale bool `json:"-"`
+}
+
+// copy returns a response with its own slice of hits, so transformers
+// working on one don't disturb the other.
+func (r *AlgoliaSearchResponse) copy() *AlgoliaSearchResponse {
+	return &AlgoliaSearchResponse{
+		Hits:  append([]AlgoliaSearchHit(nil), r.Hits...),
+		Stale: r.Stale,
+	}
 }
 
 type AlgoliaSearchHit struct {
@@ -32,6 +61,8 @@ type AlgoliaSearchHit struct {
 	URL         string
 	Author      string
 	CreatedAt   string `json:"created_at"`
+	CreatedAtI  int64  `json:"created_at_i"`
+	UpdatedAt   string `json:"updated_at"`
 	StoryTitle  string `json:"story_title"`
 	CommentText string `json:"comment_text"`
 	StoryText   string `json:"story_text"`
@@ -39,6 +70,52 @@ type AlgoliaSearchHit struct {
 	Points      int    `json:"points"`
 	StoryID     int    `json:"story_id"`
 	ParentID    int    `json:"parent_id"`
+
+	// PollOptions is filled in separately for polls, see loadPollOptions.
+	PollOptions []PollOption `json:"-"`
+
+	// HighlightResult and SnippetResult are only used with highlight=1,
+	// see applyHighlights and GetSnippet.
+	HighlightResult *highlightResult `json:"_highlightResult"`
+	SnippetResult   *highlightResult `json:"_snippetResult"`
+
+	// Previous holds earlier submissions of the same URL, filled in by
+	// loadPreviousSubmissions.
+	Previous []AlgoliaSearchHit `json:"-"`
+}
+
+// PollOption is a single choice in a poll along with its current score.
+type PollOption struct {
+	Text   string `json:"text"`
+	Points int    `json:"points"`
+}
+
+// AlgoliaItem is a single item, along with all of its descendants, as
+// returned by Algolia's items endpoint.
+type AlgoliaItem struct {
+	ID        int           `json:"id"`
+	CreatedAt string        `json:"created_at"`
+	Type      string        `json:"type"`
+	Author    string        `json:"author"`
+	Title     string        `json:"title"`
+	URL       string        `json:"url"`
+	Text      string        `json:"text"`
+	Points    int           `json:"points"`
+	ParentID  int           `json:"parent_id"`
+	StoryID   int           `json:"story_id"`
+	Children  []AlgoliaItem `json:"children"`
+	Options   []AlgoliaItem `json:"options"`
+}
+
+func (item AlgoliaItem) GetPermalink() string {
+	return hackerNewsItemID + strconv.Itoa(item.ID)
+}
+
+func (item AlgoliaItem) GetCreatedAt() time.Time {
+	if rv, err := time.Parse("2006-01-02T15:04:05.000Z", item.CreatedAt); err == nil {
+		return rv
+	}
+	return UTCNow()
 }
 
 func (hit AlgoliaSearchHit) isComment() bool {
@@ -50,6 +127,15 @@ func (hit AlgoliaSearchHit) isComment() bool {
 	return false
 }
 
+func (hit AlgoliaSearchHit) isPoll() bool {
+	for _, tag := range hit.Tags {
+		if tag == "poll" {
+			return true
+		}
+	}
+	return false
+}
+
 func (hit AlgoliaSearchHit) isSelfPost() bool {
 	return hit.StoryText != ""
 }
@@ -61,57 +147,138 @@ func (hit AlgoliaSearchHit) GetTitle() string {
 	return html.UnescapeString(hit.Title)
 }
 
-func (hit AlgoliaSearchHit) GetPermalink() string {
-	return hackerNewsItemID + hit.ObjectID
+// GetTags returns the item type tags Algolia assigns, such as story,
+// show_hn or comment, leaving out the per-author and per-story tags.
+func (hit AlgoliaSearchHit) GetTags() []string {
+	var tags []string
+	for _, tag := range hit.Tags {
+		if !strings.HasPrefix(tag, "author_") && !strings.HasPrefix(tag, "story_") {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
 }
 
-func (hit AlgoliaSearchHit) GetURL(linkTo string) string {
-	if linkTo == "" {
-		linkTo = "url"
+// GetDomain returns the host of the canonical article URL without any
+// leading "www.".
+func (hit AlgoliaSearchHit) GetDomain() string {
+	u, err := url.Parse(canonicalURL(hit.URL))
+	if err != nil {
+		return ""
 	}
+	return strings.TrimPrefix(u.Hostname(), "www.")
+}
 
-	if linkTo == "url" && hit.URL != "" {
-		return hit.URL
+// GetCommentFeed returns this server's feed of comments on the item's
+// story, with ext selecting the format (e.g. ".atom").
+func (hit AlgoliaSearchHit) GetCommentFeed(ext string) string {
+	id := hit.ObjectID
+	if hit.isComment() {
+		id = strconv.Itoa(hit.StoryID)
 	}
-	return hit.GetPermalink()
+	return SiteURL + "/item" + ext + "?id=" + id
+}
 
+func (hit AlgoliaSearchHit) GetAuthorURL() string {
+	return hackerNewsUserID + hit.Author
 }
 
-func buildTemplateEngine(name string) *template.Template {
-	fm := make(template.FuncMap)
-	fm["unescapeHTML"] = html.UnescapeString
-	t := template.New(name)
-	return t.Funcs(fm)
+// GetType returns the most specific kind of item this is, e.g. "show_hn"
+// rather than "story".
+func (hit AlgoliaSearchHit) GetType() string {
+	for _, kind := range []string{"comment", "poll", "job", "ask_hn", "show_hn", "story"} {
+		for _, tag := range hit.Tags {
+			if tag == kind {
+				return kind
+			}
+		}
+	}
+	return ""
 }
 
-func (hit AlgoliaSearchHit) GetDescription() string {
-	var (
-		b bytes.Buffer
-		t = buildTemplateEngine("default")
-	)
+// FormatTitle returns the title laid out according to format, which may
+// use the placeholders {title}, {points}, {comments}, {domain}, {author}
+// and {type}. A bracketed placeholder with no value is removed along with
+// its brackets. An empty format gives the plain title.
+func (hit AlgoliaSearchHit) FormatTitle(format string) string {
+	if format == "" {
+		return hit.GetTitle()
+	}
 
-	if hit.isComment() {
-		t = template.Must(t.Parse(`
-<p>{{ .CommentText | unescapeHTML }}</p>
-`))
-	} else if hit.isSelfPost() {
-		t = template.Must(t.Parse(`
-<p>{{ .StoryText | unescapeHTML }}</p>
-<hr>
-<p>Comments URL: <a href="{{ .GetPermalink }}">{{ .GetPermalink }}</a></p>
-<p>Points: {{ .Points }}</p>
-<p># Comments: {{ .NumComments }}</p>
-`))
-	} else {
-		t = template.Must(t.Parse(`
-{{ if .URL }}<p>Article URL: <a href="{{ .URL }}">{{ .URL }}</a></p>{{ end }}
-<p>Comments URL: <a href="{{ .GetPermalink }}">{{ .GetPermalink }}</a></p>
-<p>Points: {{ .Points }}</p>
-<p># Comments: {{ .NumComments }}</p>
-`))
-	}
-
-	t.Execute(&b, hit)
+	values := map[string]string{
+		"{title}":    hit.GetTitle(),
+		"{points}":   strconv.Itoa(hit.Points),
+		"{comments}": strconv.Itoa(hit.NumComments),
+		"{domain}":   hit.GetDomain(),
+		"{author}":   hit.Author,
+		"{type}":     hit.GetType(),
+	}
+
+	format = bracketedPlaceholder.ReplaceAllStringFunc(format, func(m string) string {
+		sub := bracketedPlaceholder.FindStringSubmatch(m)
+		placeholder := sub[1] + sub[2]
+		if v, ok := values[placeholder]; ok && v == "" {
+			return ""
+		}
+		return m
+	})
+
+	pairs := make([]string, 0, 2*len(values))
+	for placeholder, v := range values {
+		pairs = append(pairs, placeholder, v)
+	}
+	return strings.TrimSpace(strings.NewReplacer(pairs...).Replace(format))
+}
+
+func (hit AlgoliaSearchHit) GetPermalink() string {
+	return hackerNewsItemID + hit.ObjectID
+}
+
+// GetURL returns where the item should link to for link=linkTo, see
+// linkTargets. Discussion links go through the given front-end.
+func (hit AlgoliaSearchHit) GetURL(linkTo, frontend string) string {
+	switch linkTo {
+	case "comments":
+		return hit.GetDiscussionURL(frontend)
+	case "archive":
+		if hit.URL != "" {
+			return waybackMachineURL + hit.URL
+		}
+	default:
+		if hit.URL != "" {
+			return hit.URL
+		}
+	}
+	return hit.GetDiscussionURL(frontend)
+}
+
+// GetDiscussionURL returns the item's permalink on the named front-end,
+// or on HN itself if frontend is empty.
+func (hit AlgoliaSearchHit) GetDiscussionURL(frontend string) string {
+	if prefix, ok := frontends[frontend]; ok {
+		if strings.Contains(prefix, "{id}") {
+			return strings.Replace(prefix, "{id}", hit.ObjectID, -1)
+		}
+		return prefix + hit.ObjectID
+	}
+	return hit.GetPermalink()
+}
+
+// GetDescription renders the item's description in the named style, see
+// descriptionStyles.
+func (hit AlgoliaSearchHit) GetDescription(style string) string {
+	if style == "" {
+		style = DescriptionDefault
+	}
+	t, ok := descriptionStyles[style]
+	if !ok || t == nil {
+		return ""
+	}
+
+	var b bytes.Buffer
+	if err := t.Execute(&b, hit); err != nil {
+		return ""
+	}
 	return b.String()
 }
 
@@ -122,19 +289,139 @@ func (hit AlgoliaSearchHit) GetCreatedAt() time.Time {
 	return UTCNow()
 }
 
-func GetResults(params url.Values) (*AlgoliaSearchResponse, error) {
-	resp, err := algoliaClient.Get(algoliaSearchURL + params.Encode())
+// GetUpdatedAt returns when Algolia last saw the item change, falling back
+// to when it was created.
+func (hit AlgoliaSearchHit) GetUpdatedAt() time.Time {
+	if rv, err := time.Parse("2006-01-02T15:04:05Z", hit.UpdatedAt); err == nil {
+		return rv
+	}
+	return hit.GetCreatedAt()
+}
+
+func GetResults(ctx context.Context, params url.Values) (*AlgoliaSearchResponse, error) {
+	var parsed AlgoliaSearchResponse
+	if err := fetchAlgolia(ctx, "search", algoliaSearchURL+params.Encode(), &parsed); err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
+// GetFeedResults runs the search behind a feed. If Algolia is unavailable,
+// the last good results of the same search are returned instead, marked
+// as stale.
+func GetFeedResults(ctx context.Context, params url.Values) (*AlgoliaSearchResponse, error) {
+	key := params.Encode()
+	results, err := GetResults(ctx, params)
+	if err != nil {
+		if cached, ok := staleResults.Get(key); ok && upstreamUnavailable(err) {
+			loggerFor(ctx).Warn("serving stale results", "params", key, "error", err)
+			stale := cached.(*AlgoliaSearchResponse).copy()
+			stale.Stale = true
+			return stale, nil
+		}
+		return nil, err
+	}
+	staleResults.Set(key, results.copy())
+	return results, nil
+}
+
+// GetItem fetches an item and its full tree of children. Items are cached
+// briefly and shared between callers, so they must not be modified.
+func GetItem(ctx context.Context, id string) (*AlgoliaItem, error) {
+	if cached, ok := itemCache.Get(id); ok {
+		return cached.(*AlgoliaItem), nil
+	}
+
+	item, err := fetchItem(ctx, id)
 	if err != nil {
-		return nil, errors.New("error getting search results from Algolia")
+		return nil, err
+	}
+	itemCache.Set(id, item)
+	return item, nil
+}
+
+// fetchItem is GetItem without the cache.
+func fetchItem(ctx context.Context, id string) (*AlgoliaItem, error) {
+	var parsed AlgoliaItem
+	if err := fetchAlgolia(ctx, "items", algoliaItemsURL+url.PathEscape(id), &parsed); err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
+// fetchAlgolia decodes the JSON response from u into v. Temporary failures
+// are retried with backoff, and calls fail fast while the circuit breaker
+// is open. endpoint names the Algolia endpoint in metrics.
+func fetchAlgolia(ctx context.Context, endpoint, u string, v interface{}) error {
+	if !breaker.Allow() {
+		upstreamErrors.WithLabelValues(endpoint, upstreamCircuitOpen).Inc()
+		upstream.record(errCircuitOpen)
+		return errCircuitOpen
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fetchAlgoliaOnce(ctx, endpoint, u, v)
+
+		var ue *upstreamError
+		if err == nil || !errors.As(err, &ue) || !ue.temporary() || attempt == upstreamAttempts {
+			break
+		}
+		if backoff(ctx, attempt) != nil {
+			break
+		}
+	}
+
+	if ctx.Err() != nil {
+		// The client gave up, which says nothing about Algolia.
+		breaker.Release()
+		return err
+	}
+	breaker.Record(upstreamUnavailable(err))
+	upstream.record(err)
+	return err
+}
+
+func fetchAlgoliaOnce(ctx context.Context, endpoint, u string, v interface{}) error {
+	log := loggerFor(ctx)
+	start := time.Now()
+	defer func() {
+		upstreamDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := algoliaClient.Do(req)
+	if err != nil {
+		upstreamErrors.WithLabelValues(endpoint, upstreamTransport).Inc()
+		err = fmt.Errorf("error getting results from Algolia: %w", err)
+		log.Warn("upstream request failed", "url", u, "latency", time.Since(start), "error", err)
+		return &upstreamError{Reason: upstreamTransport, Err: err}
 	}
 	defer resp.Body.Close()
 
-	var parsed AlgoliaSearchResponse
+	if resp.StatusCode != http.StatusOK {
+		upstreamErrors.WithLabelValues(endpoint, upstreamStatus).Inc()
+		log.Warn("upstream request failed", "url", u, "status", resp.StatusCode, "latency", time.Since(start))
+		return &upstreamError{
+			Reason: upstreamStatus,
+			Status: resp.StatusCode,
+			Err:    fmt.Errorf("Algolia returned %s", resp.Status),
+		}
+	}
+
 	decoder := json.NewDecoder(resp.Body)
-	err = decoder.Decode(&parsed)
+	err = decoder.Decode(v)
 	if err != nil {
-		return nil, errors.New("invalid JSON received from Algolia")
+		upstreamErrors.WithLabelValues(endpoint, upstreamDecode).Inc()
+		err = fmt.Errorf("invalid JSON received from Algolia: %w", err)
+		log.Warn("upstream request failed", "url", u, "status", resp.StatusCode, "latency", time.Since(start), "error", err)
+		return &upstreamError{Reason: upstreamDecode, Status: resp.StatusCode, Err: err}
 	}
 
-	return &parsed, nil
+	log.Debug("upstream request", "url", u, "status", resp.StatusCode, "latency", time.Since(start))
+	return nil
 }
@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestFormatTitle(t *testing.T) {
+	story := AlgoliaSearchHit{
+		Tags:        []string{"story", "author_pg", "story_1"},
+		ObjectID:    "1",
+		Title:       "Go 1.23 &amp; you",
+		URL:         "https://www.go.dev/blog/go1.23",
+		Author:      "pg",
+		Points:      42,
+		NumComments: 7,
+	}
+	selfpost := AlgoliaSearchHit{
+		Tags:     []string{"story", "ask_hn"},
+		ObjectID: "2",
+		Title:    "Ask HN: What does {points} mean?",
+		Author:   "dang",
+		Points:   3,
+	}
+
+	for _, tt := range []struct {
+		hit    AlgoliaSearchHit
+		format string
+		want   string
+	}{
+		{story, "", "Go 1.23 & you"},
+		{story, "{title}", "Go 1.23 & you"},
+		{story, "{title} ({points} points, {comments} comments)", "Go 1.23 & you (42 points, 7 comments)"},
+		{story, "{title} ({domain})", "Go 1.23 & you (go.dev)"},
+		{story, "[{type}] {title} by {author}", "[story] Go 1.23 & you by pg"},
+		{story, "{title} ( {domain} )", "Go 1.23 & you ( go.dev )"},
+		{story, "{title} ({unknown})", "Go 1.23 & you ({unknown})"},
+
+		// Bracketed placeholders without a value go, brackets and all.
+		{selfpost, "{title} ({domain})", "Ask HN: What does {points} mean?"},
+		{selfpost, "{title} [{domain}]", "Ask HN: What does {points} mean?"},
+		{selfpost, "({domain}) {title}", "Ask HN: What does {points} mean?"},
+		{selfpost, "{title} ({domain}) [{points}]", "Ask HN: What does {points} mean? [3]"},
+
+		// Placeholders are only replaced once, so the title's own braces
+		// survive.
+		{selfpost, "{title} ({points})", "Ask HN: What does {points} mean? (3)"},
+	} {
+		if got := tt.hit.FormatTitle(tt.format); got != tt.want {
+			t.Errorf("FormatTitle(%q) on item %s = %q, want %q", tt.format, tt.hit.ObjectID, got, tt.want)
+		}
+	}
+}
@@ -2,23 +2,32 @@ package main
 
 // https://validator.w3.org/feed/docs/atom.html
 type Atom struct {
-	XMLName string      `xml:"feed"`
-	NS      string      `xml:"xmlns,attr"`
-	ID      string      `xml:"id"`
-	Title   string      `xml:"title"`
-	Updated string      `xml:"updated"`
-	Links   []AtomLink  `xml:"link"`
-	Entries []AtomEntry `xml:"entry"`
+	XMLName     string        `xml:"feed"`
+	NS          string        `xml:"xmlns,attr"`
+	NSThreading string        `xml:"xmlns:thr,attr"`
+	ID          string        `xml:"id"`
+	Title       string        `xml:"title"`
+	Subtitle    string        `xml:"subtitle"`
+	Icon        string        `xml:"icon"`
+	Generator   AtomGenerator `xml:"generator"`
+	Updated     string        `xml:"updated"`
+	Links       []AtomLink    `xml:"link"`
+	Entries     []AtomEntry   `xml:"entry"`
 }
 
 type AtomEntry struct {
-	Title     CDATA       `xml:"title"`
-	Links     []AtomLink  `xml:"link"`
-	Author    string      `xml:"author>name"`
-	Content   AtomContent `xml:"content"`
-	Updated   string      `xml:"updated"`
-	Published string      `xml:"published"`
-	ID        string      `xml:"id"`
+	Title      CDATA          `xml:"title"`
+	Links      []AtomLink     `xml:"link"`
+	Author     AtomPerson     `xml:"author"`
+	Categories []AtomCategory `xml:"category"`
+	Summary    AtomContent    `xml:"summary"`
+	Content    AtomContent    `xml:"content"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published"`
+	ID         string         `xml:"id"`
+
+	// https://tools.ietf.org/html/rfc4685
+	Total *int `xml:"thr:total,omitempty"`
 }
 
 type AtomContent struct {
@@ -32,29 +41,63 @@ type AtomLink struct {
 	Type         string `xml:"type,attr,omitempty"`
 }
 
+type AtomPerson struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+type AtomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type AtomGenerator struct {
+	URI     string `xml:"uri,attr"`
+	Version string `xml:"version,attr,omitempty"`
+	Value   string `xml:",chardata"`
+}
+
 func resultsAsAtom(results *AlgoliaSearchResponse, op *outputParams) *Atom {
 	atom := Atom{
-		NS:      NSAtom,
-		ID:      op.SelfLink,
-		Title:   op.Title,
-		Updated: Timestamp("atom", UTCNow()),
+		NS:          NSAtom,
+		NSThreading: NSThreading,
+		ID:          op.SelfLink,
+		Title:       op.Title,
+		Subtitle:    "Hacker News RSS",
+		Icon:        SiteURL + "/favicon.ico",
+		Generator:   AtomGenerator{"https://github.com/edavis/go-hnrss", buildString, "go-hnrss"},
+		Updated:     Timestamp("atom", UTCNow()),
 		Links: []AtomLink{
 			{op.SelfLink, "self", "application/atom+xml"},
+			{op.Link, "alternate", "text/html"},
 		},
 		Entries: make([]AtomEntry, len(results.Hits)),
 	}
 
 	for i, hit := range results.Hits {
+		description := hit.GetDescription(op.Description)
 		entry := AtomEntry{
 			ID:        hit.GetPermalink(),
-			Title:     CDATA{hit.GetTitle()},
+			Title:     CDATA{hit.FormatTitle(op.TitleFormat)},
 			Updated:   Timestamp("atom", hit.GetCreatedAt()),
 			Published: Timestamp("atom", hit.GetCreatedAt()),
 			Links: []AtomLink{
-				{hit.GetURL(op.LinkTo), "alternate", ""},
+				{hit.GetURL(op.LinkTo, op.Frontend), "alternate", ""},
+				{hit.GetDiscussionURL(op.Frontend), "related", "text/html"},
+				{hit.GetCommentFeed(".atom"), "replies", "application/atom+xml"},
 			},
-			Author:  hit.Author,
-			Content: AtomContent{"html", hit.GetDescription()},
+			Author:  AtomPerson{hit.Author, hit.GetAuthorURL()},
+			Summary: AtomContent{"text", truncateText(htmlToText(description), 280)},
+			Content: AtomContent{"html", description},
+		}
+		if op.Content == "text" || op.Content == "markdown" {
+			entry.Content = AtomContent{"text", convertContent(description, op.Content)}
+		}
+		for _, tag := range hit.GetTags() {
+			entry.Categories = append(entry.Categories, AtomCategory{tag})
+		}
+		if !hit.isComment() {
+			total := hit.NumComments
+			entry.Total = &total
 		}
 		atom.Entries[i] = entry
 	}
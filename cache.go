@@ -0,0 +1,81 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const cacheMaxEntries = 10000
+
+type cacheEntry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+// ttlCache is a small in-memory cache whose entries expire after a fixed TTL.
+// Once it holds maxEntries, the oldest entry makes way for each new one.
+type ttlCache struct {
+	mu         sync.Mutex
+	name       string
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List // oldest first
+}
+
+// newTTLCache returns an empty cache holding at most maxEntries. name
+// identifies it in metrics.
+func newTTLCache(name string, ttl time.Duration, maxEntries int) *ttlCache {
+	return &ttlCache{
+		name:       name,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+func (c *ttlCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if ok && time.Now().After(el.Value.(*cacheEntry).expires) {
+		c.remove(el)
+		ok = false
+	}
+	if !ok {
+		cacheLookups.WithLabelValues(c.name, "miss").Inc()
+		return nil, false
+	}
+	cacheLookups.WithLabelValues(c.name, "hit").Inc()
+	return el.Value.(*cacheEntry).value, true
+}
+
+func (c *ttlCache) Set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	for c.order.Len() >= c.maxEntries {
+		c.remove(c.order.Front())
+	}
+	c.entries[key] = c.order.PushBack(&cacheEntry{key, value, time.Now().Add(c.ttl)})
+}
+
+func (c *ttlCache) remove(el *list.Element) {
+	delete(c.entries, el.Value.(*cacheEntry).key)
+	c.order.Remove(el)
+}
+
+// Len returns the number of entries, including any that have expired but
+// not yet been evicted.
+func (c *ttlCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// trackingParams are query parameters that only identify where a visitor
+// came from. Anything starting with utm_ is also dropped.
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"dclid":   true,
+	"msclkid": true,
+	"yclid":   true,
+	"igshid":  true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"_hsenc":  true,
+	"_hsmi":   true,
+	"ref_src": true,
+	"ref_url": true,
+}
+
+// canonicalURL normalizes an article URL so the same article submitted
+// with different tracking parameters, host case or default port compares
+// equal. URLs that can't be parsed are returned unchanged.
+func canonicalURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return raw
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && !(u.Scheme == "http" && port == "80") && !(u.Scheme == "https" && port == "443") {
+		host += ":" + port
+	}
+	u.Host = host
+	u.Fragment = ""
+	if u.Path == "" {
+		u.Path = "/"
+	}
+
+	u.RawQuery = stripTrackingParams(u.RawQuery)
+
+	return u.String()
+}
+
+// stripTrackingParams removes tracking parameters from a raw query string,
+// leaving every other parameter exactly as it was.
+func stripTrackingParams(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	var kept []string
+	for _, pair := range strings.Split(rawQuery, "&") {
+		key := strings.SplitN(pair, "=", 2)[0]
+		if unescaped, err := url.QueryUnescape(key); err == nil {
+			key = unescaped
+		}
+		key = strings.ToLower(key)
+		if trackingParams[key] || strings.HasPrefix(key, "utm_") {
+			continue
+		}
+		kept = append(kept, pair)
+	}
+	return strings.Join(kept, "&")
+}
+
+// canonicalLinks rewrites article URLs to their canonical form.
+func canonicalLinks(hits []AlgoliaSearchHit) []AlgoliaSearchHit {
+	for i := range hits {
+		if hits[i].URL != "" {
+			hits[i].URL = canonicalURL(hits[i].URL)
+		}
+	}
+	return hits
+}
+
+// duplicateKey identifies submissions of the same article. On top of
+// canonicalURL it ignores the scheme, a leading "www." and a trailing slash.
+func duplicateKey(raw string) string {
+	u, err := url.Parse(canonicalURL(raw))
+	if err != nil || u.Host == "" {
+		return raw
+	}
+	key := strings.TrimPrefix(u.Host, "www.") + strings.TrimSuffix(u.Path, "/")
+	if u.RawQuery != "" {
+		key += "?" + u.RawQuery
+	}
+	return key
+}
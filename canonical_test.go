@@ -0,0 +1,57 @@
+package main
+
+import "testing"
+
+func TestCanonicalURL(t *testing.T) {
+	for _, tt := range []struct {
+		raw, want string
+	}{
+		{"https://example.com/a", "https://example.com/a"},
+		{"  https://example.com/a  ", "https://example.com/a"},
+		{"HTTPS://Example.COM/Path", "https://example.com/Path"},
+		{"https://example.com:443/a", "https://example.com/a"},
+		{"http://example.com:80/a", "http://example.com/a"},
+		{"http://example.com:8080/a", "http://example.com:8080/a"},
+		{"https://example.com:80/a", "https://example.com:80/a"},
+		{"https://example.com", "https://example.com/"},
+		{"https://example.com/a#section", "https://example.com/a"},
+
+		// Tracking parameters go, matched case-insensitively and after
+		// unescaping, leaving no "?" behind when nothing else is left.
+		{"https://example.com/a?utm_source=hn&id=3&fbclid=x", "https://example.com/a?id=3"},
+		{"https://example.com/a?UTM_Medium=rss&id=3", "https://example.com/a?id=3"},
+		{"https://example.com/a?utm%5Fsource=hn&id=3", "https://example.com/a?id=3"},
+		{"https://example.com/a?ref_src=twsrc&gclid=1", "https://example.com/a"},
+
+		// Everything else is kept byte-for-byte and in order.
+		{"https://example.com/a?ref=hn&source=rss", "https://example.com/a?ref=hn&source=rss"},
+		{"https://example.com/a?b=2&a=%20x+y&flag&utm_campaign=z", "https://example.com/a?b=2&a=%20x+y&flag"},
+		{"https://example.com/a?q=a%2Bb&q=c", "https://example.com/a?q=a%2Bb&q=c"},
+
+		// Anything without a host is returned unchanged.
+		{"", ""},
+		{"not a url", "not a url"},
+		{"/item?id=1", "/item?id=1"},
+		{"https://exa mple.com/%zz", "https://exa mple.com/%zz"},
+	} {
+		if got := canonicalURL(tt.raw); got != tt.want {
+			t.Errorf("canonicalURL(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestDuplicateKey(t *testing.T) {
+	want := duplicateKey("https://example.com/a?id=3")
+	for _, raw := range []string{
+		"http://example.com/a?id=3",
+		"https://www.example.com/a/?id=3",
+		"https://EXAMPLE.com:443/a?utm_source=hn&id=3#top",
+	} {
+		if got := duplicateKey(raw); got != want {
+			t.Errorf("duplicateKey(%q) = %q, want %q", raw, got, want)
+		}
+	}
+	if a, b := duplicateKey("https://example.com/a?id=3"), duplicateKey("https://example.com/a?id=4"); a == b {
+		t.Errorf("duplicateKey gave %q for different queries", a)
+	}
+}
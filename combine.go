@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// combinedFeed is one of the feeds requested from /combine.
+type combinedFeed struct {
+	sp searchParams
+	op outputParams
+}
+
+// parseCombinedFeed turns a feed reference such as "/newest?q=go" into the
+// parameters of the feed definition it names.
+func parseCombinedFeed(ref string) (*combinedFeed, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return nil, fmt.Errorf("invalid feed %q", ref)
+	}
+	def, ok := feedsByPath[u.Path]
+	if !ok {
+		return nil, fmt.Errorf("unknown feed %q", u.Path)
+	}
+
+	var feed combinedFeed
+	if err := bindSearchParams(u.Query(), &feed.sp); err != nil {
+		return nil, err
+	}
+	feed.op.Transformers, err = parseTransformers(u.Query().Get("transform"))
+	if err != nil {
+		return nil, err
+	}
+	if err := def.apply(&feed.sp, &feed.op); err != nil {
+		return nil, err
+	}
+	return &feed, nil
+}
+
+// combineHandler merges several feeds, e.g.
+// /combine?feed=/newest?q=go&feed=/show?q=rust, into one.
+func combineHandler(c *gin.Context) {
+	var sp searchParams
+	var op outputParams
+	if err := ParseRequest(c, &sp, &op); err != nil {
+		return
+	}
+
+	refs := c.QueryArray("feed")
+	if len(refs) == 0 {
+		c.String(http.StatusBadRequest, "at least one feed is required")
+		return
+	}
+	if len(refs) > CombineFeedsLimit {
+		c.String(http.StatusBadRequest, fmt.Sprintf("at most %d feeds can be combined", CombineFeedsLimit))
+		return
+	}
+
+	feeds := make([]*combinedFeed, len(refs))
+	titles := make([]string, len(refs))
+	for i, ref := range refs {
+		feed, err := parseCombinedFeed(ref)
+		if err != nil {
+			c.Error(err)
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		feeds[i] = feed
+
+		title := strings.TrimPrefix(feed.op.Title, "Hacker News: ")
+		titles[i] = strings.TrimPrefix(title, "Hacker News - ")
+	}
+
+	results, err := fetchCombined(c.Request.Context(), feeds)
+	if err != nil {
+		c.Error(err)
+		c.String(http.StatusBadGateway, err.Error())
+		return
+	}
+	if limit := sp.hitsPerPage(); len(results.Hits) > limit {
+		results.Hits = results.Hits[:limit]
+	}
+
+	op.Title = "Hacker News: " + strings.Join(titles, " + ")
+	op.Link = "https://news.ycombinator.com/"
+
+	writeResults(c, results, &sp, &op)
+}
+
+// fetchCombined queries every feed concurrently and merges the hits newest
+// first, dropping any that appear in more than one feed.
+func fetchCombined(ctx context.Context, feeds []*combinedFeed) (*AlgoliaSearchResponse, error) {
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		merged  AlgoliaSearchResponse
+		lastErr error
+	)
+	for _, feed := range feeds {
+		wg.Add(1)
+		go func(feed *combinedFeed) {
+			defer wg.Done()
+			results, err := GetResults(ctx, feed.sp.Values())
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				lastErr = err
+				return
+			}
+			merged.Hits = append(merged.Hits, applyTransformers(results.Hits, feed.op.Transformers)...)
+		}(feed)
+	}
+	wg.Wait()
+
+	if lastErr != nil {
+		return nil, lastErr
+	}
+
+	merged.Hits = dedupeHits(merged.Hits)
+	sort.SliceStable(merged.Hits, func(i, j int) bool {
+		return merged.Hits[i].CreatedAtI > merged.Hits[j].CreatedAtI
+	})
+	return &merged, nil
+}
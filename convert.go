@@ -0,0 +1,182 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	extraNewlines = regexp.MustCompile(`\n{3,}`)
+
+	// markdownEscaper escapes characters that would otherwise be read as
+	// inline Markdown. A leading ">" is left alone since HN uses it for
+	// quoting.
+	markdownEscaper = strings.NewReplacer(`\`, `\\`, "#", `\#`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`)
+
+	markdownURLEscaper = strings.NewReplacer("(", "%28", ")", "%29", " ", "%20")
+)
+
+func parseFragment(s string) []*html.Node {
+	context := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(s), context)
+	if err != nil {
+		return nil
+	}
+	return nodes
+}
+
+// convertContent converts an HTML description to the given content mode:
+// "html" (the default), "text" or "markdown".
+func convertContent(description, mode string) string {
+	switch mode {
+	case "text":
+		return htmlToText(description)
+	case "markdown":
+		return htmlToMarkdown(description)
+	default:
+		return description
+	}
+}
+
+// htmlToMarkdown converts HN-flavored HTML into Markdown, keeping links,
+// emphasis, code blocks and paragraph breaks.
+func htmlToMarkdown(s string) string {
+	var b strings.Builder
+	for _, n := range parseFragment(s) {
+		writeMarkdown(&b, n, false)
+	}
+	return strings.TrimSpace(extraNewlines.ReplaceAllString(b.String(), "\n\n"))
+}
+
+// writeMarkdown writes n as Markdown. literal is set inside code and pre
+// elements, where text is written as-is.
+func writeMarkdown(b *strings.Builder, n *html.Node, literal bool) {
+	switch n.Type {
+	case html.TextNode:
+		if literal {
+			b.WriteString(n.Data)
+		} else {
+			b.WriteString(markdownEscaper.Replace(n.Data))
+		}
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	children := func() string {
+		var inner strings.Builder
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			writeMarkdown(&inner, c, literal || n.DataAtom == atom.Pre || n.DataAtom == atom.Code)
+		}
+		return inner.String()
+	}
+
+	switch n.DataAtom {
+	case atom.Script, atom.Style:
+	case atom.P:
+		b.WriteString("\n\n" + children() + "\n\n")
+	case atom.Br:
+		b.WriteString("  \n")
+	case atom.I, atom.Em:
+		b.WriteString("*" + children() + "*")
+	case atom.B, atom.Strong, atom.Mark:
+		b.WriteString("**" + children() + "**")
+	case atom.Code:
+		if literal {
+			b.WriteString(children())
+		} else {
+			b.WriteString("`" + children() + "`")
+		}
+	case atom.Pre:
+		b.WriteString("\n\n```\n" + strings.Trim(children(), "\n") + "\n```\n\n")
+	case atom.Blockquote:
+		b.WriteString("\n\n" + quoteLines(children()) + "\n\n")
+	case atom.A:
+		text := children()
+		href := attr(n, "href")
+		if href == "" {
+			b.WriteString(text)
+		} else {
+			b.WriteString("[" + text + "](" + markdownURLEscaper.Replace(href) + ")")
+		}
+	default:
+		b.WriteString(children())
+	}
+}
+
+// quoteLines prefixes every line of s with "> ".
+func quoteLines(s string) string {
+	s = strings.TrimSpace(extraNewlines.ReplaceAllString(s, "\n\n"))
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight("> "+line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// htmlToText converts HN-flavored HTML into plain text. Links are written
+// out after their text unless the two are the same.
+func htmlToText(s string) string {
+	var b strings.Builder
+	for _, n := range parseFragment(s) {
+		writeText(&b, n)
+	}
+	return strings.TrimSpace(extraNewlines.ReplaceAllString(b.String(), "\n\n"))
+}
+
+func writeText(b *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(n.Data)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	children := func() string {
+		var inner strings.Builder
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			writeText(&inner, c)
+		}
+		return inner.String()
+	}
+
+	switch n.DataAtom {
+	case atom.Script, atom.Style:
+	case atom.P:
+		b.WriteString("\n\n" + children() + "\n\n")
+	case atom.Br:
+		b.WriteString("\n")
+	case atom.Pre:
+		b.WriteString("\n\n" + strings.Trim(children(), "\n") + "\n\n")
+	case atom.Blockquote:
+		b.WriteString("\n\n" + quoteLines(children()) + "\n\n")
+	case atom.A:
+		text, href := children(), attr(n, "href")
+		switch {
+		case href == "":
+			b.WriteString(text)
+		case strings.HasPrefix(href, strings.TrimSuffix(text, "...")):
+			// HN shortens long link text with an ellipsis.
+			b.WriteString(href)
+		default:
+			b.WriteString(text + " (" + href + ")")
+		}
+	default:
+		b.WriteString(children())
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+const (
+	DescriptionDefault = "full"
+	DescriptionNone    = "none"
+)
+
+const pollOptionsTemplate = `{{ if .PollOptions }}
+<table>
+<tr><th>Option</th><th>Points</th></tr>
+{{ range .PollOptions }}<tr><td>{{ .Text }}</td><td>{{ .Points }}</td></tr>
+{{ end }}</table>{{ end }}`
+
+const snippetTemplate = `{{ with .GetSnippet }}<blockquote><p>{{ . | sanitizeHTML }}</p></blockquote>
+{{ end }}`
+
+const previousSubmissionsTemplate = `{{ if .Previous }}
+<p>Previously submitted:</p>
+<ul>
+{{ range .Previous }}<li><a href="{{ .GetPermalink }}">{{ .Title | escapeHTML }}</a> ({{ .GetCreatedAt.Format "2006-01-02" }}, {{ .Points }} points, {{ .NumComments }} comments)</li>
+{{ end }}</ul>{{ end }}`
+
+var builtinDescriptions = map[string]string{
+	"full": `{{ template "snippet" . }}{{ if isComment . }}
+<p>{{ .CommentText | sanitizeHTML }}</p>
+{{ else if isSelfPost . }}
+<p>{{ .StoryText | sanitizeHTML }}</p>
+{{ template "poll" . }}
+<hr>
+<p>Comments URL: <a href="{{ .GetPermalink }}">{{ .GetPermalink }}</a></p>
+<p>Points: {{ .Points }}</p>
+<p># Comments: {{ .NumComments }}</p>
+{{ else }}
+{{ if .URL }}<p>Article URL: <a href="{{ .URL | escapeHTML }}">{{ .URL | escapeHTML }}</a></p>{{ end }}{{ template "poll" . }}{{ template "previous" . }}
+<p>Comments URL: <a href="{{ .GetPermalink }}">{{ .GetPermalink }}</a></p>
+<p>Points: {{ .Points }}</p>
+<p># Comments: {{ .NumComments }}</p>
+{{ end }}`,
+
+	"minimal": `{{ template "snippet" . }}{{ if isComment . }}
+<p>{{ .CommentText | sanitizeHTML }}</p>
+{{ else if isSelfPost . }}
+<p>{{ .StoryText | sanitizeHTML }}</p>
+{{ else if .URL }}
+<p><a href="{{ .URL | escapeHTML }}">{{ .URL | escapeHTML }}</a></p>
+{{ end }}`,
+
+	"comments-only": `{{ if isComment . }}{{ template "snippet" . }}
+<p>{{ .CommentText | sanitizeHTML }}</p>
+{{ end }}`,
+}
+
+// descriptionStyles maps each description=<style> to its precompiled
+// template. DescriptionNone maps to nil, which renders nothing.
+var descriptionStyles = map[string]*template.Template{
+	DescriptionNone: nil,
+}
+
+// descriptionBase holds what every description template shares: the
+// function map and the "poll", "previous" and "snippet" sub-templates.
+var descriptionBase = template.Must(template.New("base").Funcs(template.FuncMap{
+	"sanitizeHTML": sanitizeHTML,
+	"escapeHTML":   html.EscapeString,
+	"isComment":    AlgoliaSearchHit.isComment,
+	"isSelfPost":   AlgoliaSearchHit.isSelfPost,
+	"isPoll":       AlgoliaSearchHit.isPoll,
+}).New("poll").Parse(pollOptionsTemplate))
+
+func init() {
+	template.Must(descriptionBase.New("previous").Parse(previousSubmissionsTemplate))
+	template.Must(descriptionBase.New("snippet").Parse(snippetTemplate))
+	for name, text := range builtinDescriptions {
+		if err := addDescriptionStyle(name, text); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func addDescriptionStyle(name, text string) error {
+	base, err := descriptionBase.Clone()
+	if err != nil {
+		return err
+	}
+	t, err := base.New(name).Parse(text)
+	if err != nil {
+		return fmt.Errorf("description %s: %s", name, err)
+	}
+	descriptionStyles[name] = t
+	return nil
+}
+
+// loadDescriptionTemplates adds a description style for every *.tmpl file
+// in dir, named after the file. These may replace the built-in styles.
+func loadDescriptionTemplates(dir string) error {
+	filenames, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return err
+	}
+	for _, filename := range filenames {
+		name := strings.TrimSuffix(filepath.Base(filename), ".tmpl")
+		if name == DescriptionNone {
+			return fmt.Errorf("%s: %q is reserved", filename, DescriptionNone)
+		}
+
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		if err := addDescriptionStyle(name, string(data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// descriptionStyle maps the description parameter to a style name.
+// description=0 omits descriptions entirely.
+func descriptionStyle(param string) (string, error) {
+	switch param {
+	case "", "1":
+		return DescriptionDefault, nil
+	case "0":
+		return DescriptionNone, nil
+	}
+	if _, ok := descriptionStyles[param]; !ok {
+		return "", fmt.Errorf("unknown description style %q", param)
+	}
+	return param, nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func BenchmarkGetDescription(b *testing.B) {
+	hits := []struct {
+		kind string
+		hit  AlgoliaSearchHit
+	}{
+		{"story", AlgoliaSearchHit{
+			Tags:        []string{"story"},
+			ObjectID:    "1",
+			Title:       "Example story",
+			URL:         "https://example.com/article",
+			Points:      120,
+			NumComments: 45,
+		}},
+		{"comment", AlgoliaSearchHit{
+			Tags:        []string{"comment"},
+			ObjectID:    "2",
+			StoryTitle:  "Example story",
+			CommentText: "I'd disagree &amp; here's why:<p>See <a href=\"https://example.com/\">https://example.com/</a><p><i>quoted</i> text",
+		}},
+	}
+
+	for _, style := range []string{"full", "minimal", "comments-only"} {
+		for _, h := range hits {
+			b.Run(style+"/"+h.kind, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					h.hit.GetDescription(style)
+				}
+			})
+		}
+	}
+}
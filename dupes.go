@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// dupesConcurrency bounds how many earlier-submission lookups run at
+	// once for a single feed.
+	dupesConcurrency = 10
+	dupesLookupHits  = 20
+)
+
+var previousCache = newTTLCache("previous", 5*time.Minute, cacheMaxEntries)
+
+// dedupeURLs collapses stories linking to the same article into the one with
+// the most points, kept where the first of them appeared. Hits without a
+// URL are left alone.
+func dedupeURLs(hits []AlgoliaSearchHit) []AlgoliaSearchHit {
+	index := make(map[string]int)
+	rv := hits[:0]
+	for _, hit := range hits {
+		if hit.URL == "" {
+			rv = append(rv, hit)
+			continue
+		}
+		key := duplicateKey(hit.URL)
+		if i, ok := index[key]; ok {
+			if hit.Points > rv[i].Points {
+				rv[i] = hit
+			}
+			continue
+		}
+		index[key] = len(rv)
+		rv = append(rv, hit)
+	}
+	return rv
+}
+
+// previousSubmissions finds stories linking to the same article that were
+// submitted before hit, newest first.
+func previousSubmissions(ctx context.Context, hit AlgoliaSearchHit) ([]AlgoliaSearchHit, error) {
+	if cached, ok := previousCache.Get(hit.ObjectID); ok {
+		return cached.([]AlgoliaSearchHit), nil
+	}
+
+	key := duplicateKey(hit.URL)
+	query := strings.SplitN(key, "?", 2)[0]
+
+	params := make(url.Values)
+	params.Set("query", query)
+	params.Set("tags", "story")
+	params.Set("restrictSearchableAttributes", "url")
+	params.Set("numericFilters", "created_at_i<"+strconv.FormatInt(hit.CreatedAtI, 10))
+	params.Set("hitsPerPage", strconv.Itoa(dupesLookupHits))
+
+	results, err := GetResults(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous []AlgoliaSearchHit
+	for _, earlier := range results.Hits {
+		if earlier.ObjectID != hit.ObjectID && duplicateKey(earlier.URL) == key {
+			previous = append(previous, earlier)
+		}
+	}
+	previousCache.Set(hit.ObjectID, previous)
+	return previous, nil
+}
+
+// loadPreviousSubmissions fills in Previous for every story in results
+// that links somewhere. Stories whose lookups fail are left as-is.
+func loadPreviousSubmissions(ctx context.Context, results *AlgoliaSearchResponse) {
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, dupesConcurrency)
+	)
+	for i := range results.Hits {
+		hit := &results.Hits[i]
+		if hit.URL == "" || hit.isComment() {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			previous, err := previousSubmissions(ctx, *hit)
+			if err != nil {
+				return
+			}
+			hit.Previous = previous
+		}()
+	}
+	wg.Wait()
+}
+
+// dupesHandler lists new stories whose URL had already been submitted,
+// with the earlier discussions in each description. Each story checked
+// costs an Algolia search, so at most DupesLookupsLimit are checked.
+func dupesHandler(c *gin.Context) {
+	var sp searchParams
+	var op outputParams
+	if err := ParseRequest(c, &sp, &op); err != nil {
+		return
+	}
+
+	sp.Tags = "story"
+	if sp.hitsPerPage() > DupesLookupsLimit {
+		sp.Count = strconv.Itoa(DupesLookupsLimit)
+	}
+	if sp.Query != "" {
+		op.Title = "Hacker News - Resubmissions: \"" + sp.Query + "\""
+	} else {
+		op.Title = "Hacker News: Resubmissions"
+	}
+	op.Link = "https://news.ycombinator.com/newest"
+
+	results, err := GetResults(c.Request.Context(), sp.Values())
+	if err != nil {
+		c.Error(err)
+		c.String(http.StatusBadGateway, err.Error())
+		return
+	}
+	c.Header("X-Algolia-URL", algoliaSearchURL+sp.Values().Encode())
+
+	loadPreviousSubmissions(c.Request.Context(), results)
+	results.Hits = filterHits(func(hit AlgoliaSearchHit) bool {
+		return len(hit.Previous) > 0
+	}).Transform(results.Hits)
+
+	writeResults(c, results, &sp, &op)
+}
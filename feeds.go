@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v2"
+)
+
+// feedDefinition describes a feed that is nothing more than an Algolia
+// search with a title and link. Tags, Title, QueryTitle and Link are
+// templates executed against feedTemplateData.
+type feedDefinition struct {
+	Path             string `json:"path" yaml:"path"`
+	Tags             string `json:"tags" yaml:"tags"`
+	Query            string `json:"query,omitempty" yaml:"query,omitempty"`
+	Filters          string `json:"filters,omitempty" yaml:"filters,omitempty"`
+	TitlePrefix      string `json:"title_prefix,omitempty" yaml:"title_prefix,omitempty"`
+	SearchAttributes string `json:"search_attrs,omitempty" yaml:"search_attrs,omitempty"`
+	Title            string `json:"title" yaml:"title"`
+	QueryTitle       string `json:"query_title" yaml:"query_title"`
+	Link             string `json:"link" yaml:"link"`
+
+	// Transformers names built-in transformers applied to every request.
+	Transformers []string `json:"transformers,omitempty" yaml:"transformers,omitempty"`
+
+	tags, title, queryTitle, link *template.Template
+	pipeline                      []Transformer
+}
+
+// feedsByPath holds every registered feed definition, for /combine.
+var feedsByPath = make(map[string]*feedDefinition)
+
+// feedTemplateData is what a feedDefinition's templates are executed against.
+type feedTemplateData struct {
+	Query string
+	ID    string
+}
+
+var builtinFeeds = []feedDefinition{
+	{
+		Path:       "/newest",
+		Tags:       "(story,poll)",
+		Title:      "Hacker News: Newest",
+		QueryTitle: `Hacker News - Newest: "{{ .Query }}"`,
+		Link:       "https://news.ycombinator.com/newest",
+	},
+	{
+		Path:       "/frontpage",
+		Tags:       "front_page",
+		Title:      "Hacker News: Front Page",
+		QueryTitle: `Hacker News - Front Page: "{{ .Query }}"`,
+		Link:       "https://news.ycombinator.com/",
+	},
+	{
+		Path:             "/newcomments",
+		Tags:             "comment",
+		SearchAttributes: "default",
+		Title:            "Hacker News: New Comments",
+		QueryTitle:       `Hacker News - New Comments: "{{ .Query }}"`,
+		Link:             "https://news.ycombinator.com/newcomments",
+	},
+	{
+		Path:       "/ask",
+		Tags:       "ask_hn",
+		Title:      "Hacker News: Ask HN",
+		QueryTitle: `Hacker News - Ask HN: "{{ .Query }}"`,
+		Link:       "https://news.ycombinator.com/ask",
+	},
+	{
+		Path:       "/show",
+		Tags:       "show_hn",
+		Title:      "Hacker News: Show HN",
+		QueryTitle: `Hacker News - Show HN: "{{ .Query }}"`,
+		Link:       "https://news.ycombinator.com/shownew",
+	},
+	{
+		Path:        "/launches",
+		Tags:        "story",
+		TitlePrefix: "Launch HN:",
+		Title:       "Hacker News: Launch HN",
+		QueryTitle:  `Hacker News - Launch HN: "{{ .Query }}"`,
+		Link:        "https://news.ycombinator.com/launches",
+	},
+	{
+		Path:        "/tell",
+		Tags:        "story",
+		TitlePrefix: "Tell HN:",
+		Title:       "Hacker News: Tell HN",
+		QueryTitle:  `Hacker News - Tell HN: "{{ .Query }}"`,
+		Link:        "https://news.ycombinator.com/",
+	},
+	{
+		Path:       "/polls",
+		Tags:       "poll",
+		Title:      "Hacker News: Polls",
+		QueryTitle: `Hacker News - Polls: "{{ .Query }}"`,
+		Link:       "https://news.ycombinator.com/",
+	},
+	{
+		Path:       "/jobs",
+		Tags:       "job",
+		Title:      "Hacker News: Jobs",
+		QueryTitle: `Hacker News - Jobs: "{{ .Query }}"`,
+		Link:       "https://news.ycombinator.com/jobs",
+	},
+	{
+		Path:       "/user",
+		Tags:       "(story,comment,poll),author_{{ .ID }}",
+		Title:      "Hacker News: {{ .ID }}",
+		QueryTitle: `Hacker News - {{ .ID }}: "{{ .Query }}"`,
+		Link:       "https://news.ycombinator.com/user?id={{ .ID }}",
+	},
+	{
+		Path:             "/threads",
+		Tags:             "comment,author_{{ .ID }}",
+		SearchAttributes: "default",
+		Title:            "Hacker News: {{ .ID }} threads",
+		QueryTitle:       `Hacker News - {{ .ID }} threads: "{{ .Query }}"`,
+		Link:             "https://news.ycombinator.com/threads?id={{ .ID }}",
+	},
+	{
+		Path:       "/submitted",
+		Tags:       "(story,poll),author_{{ .ID }}",
+		Title:      "Hacker News: {{ .ID }} submitted",
+		QueryTitle: `Hacker News - {{ .ID }} submitted: "{{ .Query }}"`,
+		Link:       "https://news.ycombinator.com/submitted?id={{ .ID }}",
+	},
+}
+
+// reservedPaths are routes main registers itself, which a feed definition
+// can't take over. Every path under /s/ is reserved for saved searches.
+var reservedPaths = map[string]bool{
+	"/":                      true,
+	"/combine":               true,
+	"/following":             true,
+	"/replies":               true,
+	"/item":                  true,
+	"/item/tree":             true,
+	"/dupes":                 true,
+	"/whoishiring":           true,
+	"/whoishiring/jobs":      true,
+	"/whoishiring/hired":     true,
+	"/whoishiring/freelance": true,
+	"/saved":                 true,
+	"/metrics":               true,
+	"/healthz":               true,
+	"/readyz":                true,
+	"/version":               true,
+	"/favicon.ico":           true,
+	"/robots.txt":            true,
+}
+
+// compile validates the definition, then parses its templates and checks
+// they execute.
+func (def *feedDefinition) compile() error {
+	if !strings.HasPrefix(def.Path, "/") {
+		return fmt.Errorf("feed path %q must start with /", def.Path)
+	}
+	if strings.ContainsAny(def.Path, ":*") {
+		return fmt.Errorf("feed path %q can't contain : or *", def.Path)
+	}
+	if reservedPaths[def.Path] || strings.HasPrefix(def.Path, "/s/") {
+		return fmt.Errorf("feed path %q is reserved", def.Path)
+	}
+	if def.Title == "" {
+		return fmt.Errorf("feed %s: title is required", def.Path)
+	}
+	if def.QueryTitle == "" {
+		def.QueryTitle = def.Title + `: "{{ .Query }}"`
+	}
+
+	pipeline, err := parseTransformers(strings.Join(def.Transformers, ","))
+	if err != nil {
+		return fmt.Errorf("feed %s: %s", def.Path, err)
+	}
+	if def.TitlePrefix != "" {
+		pipeline = append([]Transformer{titlePrefix(def.TitlePrefix)}, pipeline...)
+	}
+	def.pipeline = pipeline
+
+	parse := func(name, text string) *template.Template {
+		if err != nil {
+			return nil
+		}
+		var t *template.Template
+		t, err = template.New(def.Path + " " + name).Option("missingkey=error").Parse(text)
+		if err == nil {
+			_, err = executeFeedTemplate(t, feedTemplateData{})
+		}
+		return t
+	}
+	def.tags = parse("tags", def.Tags)
+	def.title = parse("title", def.Title)
+	def.queryTitle = parse("query_title", def.QueryTitle)
+	def.link = parse("link", def.Link)
+	if err != nil {
+		return fmt.Errorf("feed %s: %s", def.Path, err)
+	}
+	return nil
+}
+
+func executeFeedTemplate(t *template.Template, data feedTemplateData) (string, error) {
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// apply fills in the search and output parameters for a request to this feed.
+func (def *feedDefinition) apply(sp *searchParams, op *outputParams) error {
+	if sp.Query == "" {
+		sp.Query = def.Query
+	}
+	if sp.SearchAttributes == "" {
+		sp.SearchAttributes = def.SearchAttributes
+	}
+	sp.Filters = def.Filters
+	sp.TitlePrefix = def.TitlePrefix
+	op.Transformers = append(append([]Transformer{}, def.pipeline...), op.Transformers...)
+
+	var (
+		data = feedTemplateData{Query: sp.Query, ID: sp.ID}
+		err  error
+	)
+	if sp.Tags, err = executeFeedTemplate(def.tags, data); err != nil {
+		return err
+	}
+	if op.Link, err = executeFeedTemplate(def.link, data); err != nil {
+		return err
+	}
+
+	title := def.title
+	if sp.Query != "" && sp.Query != def.Query {
+		title = def.queryTitle
+	}
+	if op.Title, err = executeFeedTemplate(title, data); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Handler returns the gin handler serving this feed.
+func (def *feedDefinition) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var sp searchParams
+		var op outputParams
+		if err := ParseRequest(c, &sp, &op); err != nil {
+			return
+		}
+
+		if err := def.apply(&sp, &op); err != nil {
+			c.Error(err)
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		renderResults(c, &sp, &op)
+	}
+}
+
+// loadFeedDefinitions reads feed definitions from a JSON or YAML file,
+// chosen by the file's extension.
+func loadFeedDefinitions(filename string) ([]feedDefinition, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var defs []feedDefinition
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, &defs)
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&defs)
+	default:
+		return nil, errors.New("feed definitions must be a .json, .yaml or .yml file")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	return defs, nil
+}
+
+// feedDefinitions returns the built-in feeds followed by any loaded from
+// filename. A loaded feed replaces a built-in one with the same path.
+func feedDefinitions(filename string) ([]*feedDefinition, error) {
+	defs := append([]feedDefinition{}, builtinFeeds...)
+	if filename != "" {
+		custom, err := loadFeedDefinitions(filename)
+		if err != nil {
+			return nil, err
+		}
+		defs = append(defs, custom...)
+	}
+
+	var (
+		rv    []*feedDefinition
+		index = make(map[string]int)
+	)
+	for i := range defs {
+		def := &defs[i]
+		if err := def.compile(); err != nil {
+			return nil, err
+		}
+		if j, ok := index[def.Path]; ok {
+			rv[j] = def
+			continue
+		}
+		index[def.Path] = len(rv)
+		rv = append(rv, def)
+	}
+	return rv, nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// readyWindow is how recently Algolia must have answered for the
+	// server to count as ready without probing it.
+	readyWindow = 2 * time.Minute
+
+	// probeInterval limits how often /readyz probes Algolia itself.
+	probeInterval = 30 * time.Second
+
+	// probeTimeout bounds a probe so it answers well within a load
+	// balancer's health check timeout.
+	probeTimeout = 2 * time.Second
+)
+
+// upstreamState tracks the outcome of recent requests to Algolia.
+type upstreamState struct {
+	mu          sync.Mutex
+	lastSuccess time.Time
+	lastFailure time.Time
+	lastError   string
+	lastProbe   time.Time
+}
+
+var upstream upstreamState
+
+func (s *upstreamState) record(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		s.lastFailure = time.Now()
+		s.lastError = err.Error()
+		return
+	}
+	s.lastSuccess = time.Now()
+}
+
+func (s *upstreamState) ready() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Since(s.lastSuccess) < readyWindow
+}
+
+// shouldProbe reports whether it's time for another probe, and if so
+// claims it so concurrent checks don't all probe at once.
+func (s *upstreamState) shouldProbe() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if time.Since(s.lastProbe) < probeInterval {
+		return false
+	}
+	s.lastProbe = time.Now()
+	return true
+}
+
+func (s *upstreamState) details() gin.H {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h := gin.H{}
+	if !s.lastSuccess.IsZero() {
+		h["last_success"] = Timestamp("jsonfeed", s.lastSuccess.UTC())
+	}
+	if !s.lastFailure.IsZero() {
+		h["last_failure"] = Timestamp("jsonfeed", s.lastFailure.UTC())
+		h["last_error"] = s.lastError
+	}
+	if !s.lastProbe.IsZero() {
+		h["last_probe"] = Timestamp("jsonfeed", s.lastProbe.UTC())
+	}
+	return h
+}
+
+// probeUpstream makes a single, quick search. It skips the retries and
+// circuit breaker of fetchAlgolia, since the point is to find out how
+// Algolia is doing right now.
+func probeUpstream(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	params := make(url.Values)
+	params.Set("tags", "front_page")
+	params.Set("hitsPerPage", "1")
+
+	var parsed AlgoliaSearchResponse
+	upstream.record(fetchAlgoliaOnce(ctx, "probe", algoliaSearchURL+params.Encode(), &parsed))
+}
+
+// healthzHandler reports that the process is up.
+func healthzHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// readyzHandler reports whether Algolia has answered recently, probing it
+// if it hasn't, along with how warm the caches are.
+func readyzHandler(c *gin.Context) {
+	if !upstream.ready() && upstream.shouldProbe() {
+		probeUpstream(c.Request.Context())
+	}
+
+	caches := gin.H{}
+	for _, cache := range []*ttlCache{itemCache, pollOptionsCache, previousCache, staleResults} {
+		n := cache.Len()
+		caches[cache.name] = gin.H{"entries": n, "warm": n > 0}
+	}
+
+	status, code := "ready", http.StatusOK
+	if !upstream.ready() {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+	details := upstream.details()
+	details["circuit_open"] = breaker.Open()
+	c.JSON(code, gin.H{
+		"status":   status,
+		"upstream": details,
+		"caches":   caches,
+	})
+}
+
+func versionHandler(c *gin.Context) {
+	version := buildString
+	if version == "" {
+		version = "unknown"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"version": version,
+		"go":      runtime.Version(),
+	})
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	highlightPreTag  = "<mark>"
+	highlightPostTag = "</mark>"
+
+	// snippetWords is how many words Algolia keeps around a match.
+	snippetWords = "30"
+)
+
+// highlightField is Algolia's highlighted or snippeted version of a
+// single attribute.
+type highlightField struct {
+	Value      string `json:"value"`
+	MatchLevel string `json:"matchLevel"`
+}
+
+func (f *highlightField) matched() bool {
+	return f != nil && f.MatchLevel != "" && f.MatchLevel != "none"
+}
+
+// highlightResult holds the attributes of a hit Algolia may highlight.
+// Only those we render are decoded.
+type highlightResult struct {
+	Title       *highlightField `json:"title"`
+	StoryText   *highlightField `json:"story_text"`
+	CommentText *highlightField `json:"comment_text"`
+}
+
+// applyHighlights swaps each hit's text for Algolia's highlighted version,
+// which wraps matched terms in <mark>. Requires sp.Highlight so Algolia
+// uses <mark> rather than its default <em>.
+func applyHighlights(hits []AlgoliaSearchHit) {
+	for i := range hits {
+		hit := &hits[i]
+		if hit.HighlightResult == nil {
+			continue
+		}
+		if f := hit.HighlightResult.CommentText; f.matched() {
+			hit.CommentText = stripHighlightsFromTags(f.Value, true)
+		}
+		if f := hit.HighlightResult.StoryText; f.matched() {
+			hit.StoryText = stripHighlightsFromTags(f.Value, true)
+		}
+	}
+}
+
+// clearHighlights drops the highlight data Algolia sends with every
+// search, so descriptions only carry snippets when highlight=1 was asked
+// for.
+func clearHighlights(hits []AlgoliaSearchHit) {
+	for i := range hits {
+		hits[i].HighlightResult = nil
+		hits[i].SnippetResult = nil
+	}
+}
+
+// GetSnippet returns the text around the first match as HTML with only
+// <mark> tags left in, preferring the body over the title. It's empty if
+// nothing matched or the highlight data was dropped by clearHighlights.
+func (hit AlgoliaSearchHit) GetSnippet() string {
+	for _, results := range []*highlightResult{hit.SnippetResult, hit.HighlightResult} {
+		if results == nil {
+			continue
+		}
+		for _, f := range []*highlightField{results.CommentText, results.StoryText, results.Title} {
+			if f.matched() {
+				return stripHighlightsFromTags(f.Value, false)
+			}
+		}
+	}
+	return ""
+}
+
+// stripHighlightsFromTags cleans up highlighted HTML. Algolia highlights
+// the raw markup, so a match can land inside an attribute such as a link's
+// href; those markers are removed. With keepTags unset every other tag is
+// dropped as well, leaving text and <mark>, which suits snippets cut from
+// the middle of a comment.
+func stripHighlightsFromTags(s string, keepTags bool) string {
+	var (
+		b strings.Builder
+		z = html.NewTokenizer(strings.NewReader(s))
+		r = strings.NewReplacer(highlightPreTag, "", highlightPostTag, "")
+	)
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if z.Err() != io.EOF {
+				return ""
+			}
+			break
+		}
+
+		tok := z.Token()
+		switch {
+		case tt == html.TextToken:
+			b.WriteString(html.EscapeString(tok.Data))
+		case tok.Data == "mark":
+			b.WriteString(tok.String())
+		case keepTags:
+			for j := range tok.Attr {
+				tok.Attr[j].Val = r.Replace(tok.Attr[j].Val)
+			}
+			b.WriteString(tok.String())
+		}
+	}
+	return b.String()
+}
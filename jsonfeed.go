@@ -1,5 +1,10 @@
 package main
 
+const (
+	JSONFeedVersion1  = "https://jsonfeed.org/version/1"
+	JSONFeedVersion11 = "https://jsonfeed.org/version/1.1"
+)
+
 // https://jsonfeed.org/version/1
 type JSONFeed struct {
 	Version     string         `json:"version"`
@@ -13,29 +18,125 @@ type JSONFeedItem struct {
 	ID          string `json:"id"`
 	Title       string `json:"title"`
 	ContentHTML string `json:"content_html"`
+	ContentText string `json:"content_text,omitempty"`
 	URL         string `json:"url"`
 	ExternalURL string `json:"external_url"`
 	Published   string `json:"date_published"`
 	Author      string `json:"author"`
+
+	HNRSS *JSONFeedExtension `json:"_hnrss,omitempty"`
+}
+
+// https://jsonfeed.org/version/1.1
+type JSONFeed11 struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	Description string           `json:"description"`
+	Link        string           `json:"home_page_url"`
+	FeedURL     string           `json:"feed_url"`
+	Icon        string           `json:"icon"`
+	Favicon     string           `json:"favicon"`
+	Language    string           `json:"language"`
+	Items       []JSONFeed11Item `json:"items"`
+}
+
+type JSONFeed11Item struct {
+	ID          string           `json:"id"`
+	Title       string           `json:"title"`
+	ContentHTML string           `json:"content_html"`
+	ContentText string           `json:"content_text"`
+	URL         string           `json:"url"`
+	ExternalURL string           `json:"external_url"`
+	Published   string           `json:"date_published"`
+	Modified    string           `json:"date_modified"`
+	Authors     []JSONFeedAuthor `json:"authors"`
+	Tags        []string         `json:"tags,omitempty"`
+
+	HNRSS *JSONFeedExtension `json:"_hnrss,omitempty"`
+}
+
+type JSONFeedAuthor struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// JSONFeedExtension holds hnrss-specific item data.
+// https://jsonfeed.org/version/1#extensions
+type JSONFeedExtension struct {
+	About       string       `json:"about"`
+	PollOptions []PollOption `json:"poll_options,omitempty"`
+}
+
+func newJSONFeedExtension(hit AlgoliaSearchHit) *JSONFeedExtension {
+	if len(hit.PollOptions) == 0 {
+		return nil
+	}
+	return &JSONFeedExtension{
+		About:       SiteURL + "/",
+		PollOptions: hit.PollOptions,
+	}
 }
 
 func resultsAsJSONFeed(results *AlgoliaSearchResponse, op *outputParams) *JSONFeed {
 	j := JSONFeed{
-		Version:     "https://jsonfeed.org/version/1",
+		Version:     JSONFeedVersion1,
 		Title:       op.Title,
 		Link:        op.Link,
 		Description: "Hacker News RSS",
 		Items:       make([]JSONFeedItem, len(results.Hits)),
 	}
 	for i, hit := range results.Hits {
+		description := hit.GetDescription(op.Description)
 		item := JSONFeedItem{
 			ID:          hit.GetPermalink(),
-			Title:       hit.GetTitle(),
-			ContentHTML: hit.GetDescription(),
-			URL:         hit.GetURL(op.LinkTo),
-			ExternalURL: hit.GetPermalink(),
+			Title:       hit.FormatTitle(op.TitleFormat),
+			ContentHTML: description,
+			URL:         hit.GetURL(op.LinkTo, op.Frontend),
+			ExternalURL: hit.GetDiscussionURL(op.Frontend),
 			Published:   Timestamp("jsonfeed", hit.GetCreatedAt()),
 			Author:      hit.Author,
+			HNRSS:       newJSONFeedExtension(hit),
+		}
+		if op.Content == "text" || op.Content == "markdown" {
+			item.ContentText = convertContent(description, op.Content)
+		}
+		j.Items[i] = item
+	}
+	return &j
+}
+
+func resultsAsJSONFeed11(results *AlgoliaSearchResponse, op *outputParams) *JSONFeed11 {
+	j := JSONFeed11{
+		Version:     JSONFeedVersion11,
+		Title:       op.Title,
+		Link:        op.Link,
+		FeedURL:     op.SelfLink,
+		Description: "Hacker News RSS",
+		Icon:        "https://news.ycombinator.com/y18.svg",
+		Favicon:     SiteURL + "/favicon.ico",
+		Language:    "en",
+		Items:       make([]JSONFeed11Item, len(results.Hits)),
+	}
+	for i, hit := range results.Hits {
+		mode := "text"
+		if op.Content == "markdown" {
+			mode = "markdown"
+		}
+		description := hit.GetDescription(op.Description)
+		item := JSONFeed11Item{
+			ID:          hit.GetPermalink(),
+			Title:       hit.FormatTitle(op.TitleFormat),
+			ContentHTML: description,
+			ContentText: convertContent(description, mode),
+			URL:         hit.GetURL(op.LinkTo, op.Frontend),
+			ExternalURL: hit.GetDiscussionURL(op.Frontend),
+			Published:   Timestamp("jsonfeed", hit.GetCreatedAt()),
+			Modified:    Timestamp("jsonfeed", hit.GetUpdatedAt()),
+			Authors: []JSONFeedAuthor{
+				{hit.Author, hit.GetAuthorURL()},
+			},
+			Tags:  hit.GetTags(),
+			HNRSS: newJSONFeedExtension(hit),
 		}
 		j.Items[i] = item
 	}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// linkTargets are the accepted values of the link parameter.
+//
+//	url      the article, or the discussion for self posts (default)
+//	comments the discussion
+//	archive  the Wayback Machine copy of the article
+//	both     the article, with the discussion as a second link
+var linkTargets = map[string]bool{
+	"":         true,
+	"url":      true,
+	"comments": true,
+	"archive":  true,
+	"both":     true,
+}
+
+// frontends maps the names accepted by frontend= to the permalink prefix
+// of an alternate HN front-end. Only operator-configured front-ends are
+// allowed.
+var frontends = make(map[string]string)
+
+// parseFrontends parses -frontends, a comma-separated list of
+// name=prefix pairs. A prefix may contain {id} where the item ID goes,
+// otherwise the ID is appended.
+func parseFrontends(s string) (map[string]string, error) {
+	rv := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("front-end %q must be name=prefix", pair)
+		}
+		u, err := url.Parse(strings.Replace(parts[1], "{id}", "0", -1))
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return nil, fmt.Errorf("front-end %s must be an https URL", parts[0])
+		}
+		rv[parts[0]] = parts[1]
+	}
+	return rv, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"flag"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -15,36 +16,92 @@ import (
 
 var (
 	bindAddr    = flag.String("bind", "127.0.0.1:9000", "HOST:PORT")
+	feedsFile   = flag.String("feeds", "", "JSON or YAML file of additional feed definitions")
+	dbPath      = flag.String("db", "", "database file for saved searches (disabled if empty)")
+	adminToken  = flag.String("admin-token", "", "bearer token for the saved search admin API")
+	templateDir = flag.String("templates", "", "directory of *.tmpl description templates")
+	frontendMap = flag.String("frontends", "", "alternate HN front-ends as name=https://host/item?id= pairs, comma-separated")
+	logLevel    = flag.String("log-level", "info", "minimum level logged: debug, info, warn or error")
 	buildString string
+
+	// endpoints maps each feed path to its handler so saved searches
+	// can be rendered through it.
+	endpoints = make(map[string]gin.HandlerFunc)
 )
 
 func registerEndpoint(r *gin.Engine, url string, fn gin.HandlerFunc) {
-	r.GET(url, SetFormat("rss"), fn)
-	r.GET(url+".jsonfeed", SetFormat("jsonfeed"), fn)
-	r.GET(url+".atom", SetFormat("atom"), fn)
+	endpoints[url] = fn
+	r.GET(url, ObserveRoute(url), NegotiateFormat(), fn)
+	for _, rd := range renderers {
+		route := url + "." + rd.Extension()
+		r.GET(route, ObserveRoute(route), SetFormat(rd.Name()), fn)
+	}
 }
 
 func main() {
-	r := gin.Default()
+	flag.Parse()
+
+	logger, err := newLogger(*logLevel)
+	if err != nil {
+		log.Fatalf("log level: %s\n", err)
+	}
+	slog.SetDefault(logger)
+
+	feeds, err := feedDefinitions(*feedsFile)
+	if err != nil {
+		log.Fatalf("feeds: %s\n", err)
+	}
+
+	frontends, err = parseFrontends(*frontendMap)
+	if err != nil {
+		log.Fatalf("frontends: %s\n", err)
+	}
+
+	if *templateDir != "" {
+		if err := loadDescriptionTemplates(*templateDir); err != nil {
+			log.Fatalf("templates: %s\n", err)
+		}
+	}
+
+	r := gin.New()
+	r.Use(RequestLogger(), gin.Recovery())
 	r.Use(gzip.Gzip(gzip.DefaultCompression))
 
-	registerEndpoint(r, "/newest", newestPostHandler)
-	registerEndpoint(r, "/frontpage", frontpageHandler)
-	registerEndpoint(r, "/newcomments", newCommentsHandler)
-	registerEndpoint(r, "/ask", askHNHandler)
-	registerEndpoint(r, "/show", showHNHandler)
-	registerEndpoint(r, "/polls", pollsHandler)
-	registerEndpoint(r, "/jobs", jobsHandler)
-	registerEndpoint(r, "/user", userAllHandler)
-	registerEndpoint(r, "/threads", userThreadsHandler)
-	registerEndpoint(r, "/submitted", userSubmittedHandler)
+	for _, def := range feeds {
+		feedsByPath[def.Path] = def
+		registerEndpoint(r, def.Path, def.Handler())
+	}
+	registerEndpoint(r, "/combine", combineHandler)
+	registerEndpoint(r, "/following", followingHandler)
 	registerEndpoint(r, "/replies", repliesHandler)
 	registerEndpoint(r, "/item", itemHandler)
+	registerEndpoint(r, "/dupes", dupesHandler)
+	r.GET("/item/tree", ObserveRoute("/item/tree"), SetFormat("json"), itemTreeHandler)
+	r.GET("/item/tree.json", ObserveRoute("/item/tree.json"), SetFormat("json"), itemTreeHandler)
+	r.GET("/item/tree.html", ObserveRoute("/item/tree.html"), SetFormat("html"), itemTreeHandler)
+	r.GET("/item/tree.md", ObserveRoute("/item/tree.md"), SetFormat("markdown"), itemTreeHandler)
 	registerEndpoint(r, "/whoishiring/jobs", seekingEmployeesHandler)
 	registerEndpoint(r, "/whoishiring/hired", seekingEmployersHandler)
 	registerEndpoint(r, "/whoishiring/freelance", seekingFreelanceHandler)
 	registerEndpoint(r, "/whoishiring", seekingAllHandler)
 
+	if *dbPath != "" {
+		savedSearches, err = openSavedStore(*dbPath)
+		if err != nil {
+			log.Fatalf("db: %s\n", err)
+		}
+		defer savedSearches.Close()
+
+		r.POST("/saved", createSavedHandler)
+		r.GET("/saved", RequireAdmin(*adminToken), listSavedHandler)
+		r.DELETE("/saved/:slug", RequireAdmin(*adminToken), deleteSavedHandler)
+		r.GET("/s/:slug", ObserveRoute("/s/:slug"), savedFeedHandler)
+	}
+
+	r.GET("/metrics", metricsHandler())
+	r.GET("/healthz", healthzHandler)
+	r.GET("/readyz", readyzHandler)
+	r.GET("/version", versionHandler)
 	r.GET("/favicon.ico", func(c *gin.Context) {
 		c.Redirect(http.StatusMovedPermanently, "https://news.ycombinator.com/favicon.ico")
 	})
@@ -55,8 +112,6 @@ func main() {
 		c.Redirect(http.StatusFound, "https://edavis.github.io/hnrss/")
 	})
 
-	flag.Parse()
-
 	srv := &http.Server{
 		Addr:    *bindAddr,
 		Handler: r,
@@ -68,7 +123,7 @@ func main() {
 		}
 	}()
 
-	quit := make(chan os.Signal)
+	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt)
 	<-quit
 	log.Println("Shutting down server...")
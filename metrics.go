@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Reasons an upstream request can fail, used as the "reason" label.
+const (
+	upstreamTransport = "transport"
+	upstreamStatus    = "non_200"
+	upstreamDecode    = "bad_json"
+
+	// upstreamCircuitOpen counts requests refused by the circuit breaker
+	// without reaching Algolia.
+	upstreamCircuitOpen = "circuit_open"
+)
+
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hnrss_http_requests_total",
+		Help: "Requests served, by route, format and status code.",
+	}, []string{"route", "format", "code"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "hnrss_http_request_duration_seconds",
+		Help:    "Time taken to serve requests, by route and format.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "format"})
+
+	upstreamDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "hnrss_upstream_request_duration_seconds",
+		Help:    "Time taken by requests to Algolia, by endpoint.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"endpoint"})
+
+	upstreamErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hnrss_upstream_errors_total",
+		Help: "Failed requests to Algolia, by endpoint and reason.",
+	}, []string{"endpoint", "reason"})
+
+	cacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hnrss_cache_lookups_total",
+		Help: "Cache lookups, by cache and result (hit or miss).",
+	}, []string{"cache", "result"})
+
+	hitsPerResponse = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "hnrss_hits_per_response",
+		Help:    "Number of items in each rendered feed.",
+		Buckets: []float64{0, 1, 5, 10, 20, 30, 50, 75, 100},
+	})
+)
+
+func init() {
+	prometheus.MustRegister(
+		requestsTotal,
+		requestDuration,
+		upstreamDuration,
+		upstreamErrors,
+		cacheLookups,
+		hitsPerResponse,
+	)
+}
+
+// ObserveRoute records the count and latency of requests to route. The
+// route is the pattern it was registered under rather than the request
+// path, so parameters such as saved search slugs don't add labels.
+func ObserveRoute(route string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Set("route", route)
+		c.Next()
+
+		format := c.GetString("format")
+		requestsTotal.WithLabelValues(route, format, strconv.Itoa(c.Writer.Status())).Inc()
+		requestDuration.WithLabelValues(route, format).Observe(time.Since(start).Seconds())
+	}
+}
+
+func metricsHandler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// roundTripFunc stands in for Algolia so tests don't need the network.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (fn roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
+
+func stubAlgolia(t *testing.T, fn roundTripFunc) {
+	t.Helper()
+	transport, cb := algoliaClient.Transport, breaker
+	algoliaClient.Transport = fn
+	breaker = &circuitBreaker{threshold: breakerThreshold, cooldown: breakerCooldown}
+	t.Cleanup(func() { algoliaClient.Transport, breaker = transport, cb })
+}
+
+func scrapeMetrics(t *testing.T, r *gin.Engine) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /metrics: status %d", w.Code)
+	}
+	return w.Body.String()
+}
+
+func TestMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var response func() (*http.Response, error)
+	stubAlgolia(t, func(req *http.Request) (*http.Response, error) {
+		return response()
+	})
+	respond := func(code int, body string) func() (*http.Response, error) {
+		return func() (*http.Response, error) {
+			return &http.Response{
+				StatusCode: code,
+				Status:     http.StatusText(code),
+				Body:       io.NopCloser(strings.NewReader(body)),
+			}, nil
+		}
+	}
+
+	r := gin.New()
+	r.GET("/metrics", metricsHandler())
+	registerEndpoint(r, "/metricstest", func(c *gin.Context) {
+		var sp searchParams
+		var op outputParams
+		if err := ParseRequest(c, &sp, &op); err != nil {
+			return
+		}
+		sp.Tags = "story"
+		renderResults(c, &sp, &op)
+	})
+
+	response = respond(http.StatusOK, `{"hits":[{"_tags":["story"],"objectID":"1","title":"One"},{"_tags":["story"],"objectID":"2","title":"Two"}]}`)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metricstest.atom", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /metricstest.atom: status %d", w.Code)
+	}
+
+	ctx := context.Background()
+	params := url.Values{"tags": {"metricstest"}}
+	response = func() (*http.Response, error) { return nil, errors.New("connection refused") }
+	if _, err := GetResults(ctx, params); err == nil {
+		t.Error("expected a transport error")
+	}
+	response = respond(http.StatusBadRequest, `{"message":"bad"}`)
+	if _, err := GetResults(ctx, params); err == nil {
+		t.Error("expected a status error")
+	}
+	response = respond(http.StatusOK, `not json`)
+	if _, err := GetResults(ctx, params); err == nil {
+		t.Error("expected a decode error")
+	}
+
+	body := scrapeMetrics(t, r)
+	for _, want := range []string{
+		`hnrss_http_requests_total{code="200",format="atom",route="/metricstest.atom"} 1`,
+		`hnrss_http_request_duration_seconds_count{format="atom",route="/metricstest.atom"} 1`,
+		`hnrss_upstream_errors_total{endpoint="search",reason="transport"}`,
+		`hnrss_upstream_errors_total{endpoint="search",reason="non_200"}`,
+		`hnrss_upstream_errors_total{endpoint="search",reason="bad_json"}`,
+		`hnrss_upstream_request_duration_seconds_count{endpoint="search"}`,
+		`hnrss_hits_per_response_bucket{le="1"} 0`,
+		`hnrss_hits_per_response_bucket{le="5"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics is missing %s", want)
+		}
+	}
+}
+
+func TestCacheMetrics(t *testing.T) {
+	cache := newTTLCache("metricstest", time.Minute, cacheMaxEntries)
+	cache.Set("a", 1)
+	cache.Get("a")
+	cache.Get("b")
+
+	r := gin.New()
+	r.GET("/metrics", metricsHandler())
+	body := scrapeMetrics(t, r)
+	for _, want := range []string{
+		`hnrss_cache_lookups_total{cache="metricstest",result="hit"} 1`,
+		`hnrss_cache_lookups_total{cache="metricstest",result="miss"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics is missing %s", want)
+		}
+	}
+}
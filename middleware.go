@@ -1,6 +1,11 @@
 package main
 
 import (
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -10,3 +15,51 @@ func SetFormat(fmt string) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// NegotiateFormat picks the format from the request's Accept header,
+// falling back to the default renderer.
+func NegotiateFormat() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Vary", "Accept")
+		c.Set("format", negotiateRenderer(c.GetHeader("Accept")).Name())
+		c.Next()
+	}
+}
+
+type acceptedType struct {
+	mediaType string
+	q         float64
+}
+
+func negotiateRenderer(accept string) Renderer {
+	var accepted []acceptedType
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			accepted = append(accepted, acceptedType{mediaType, q})
+		}
+	}
+	sort.SliceStable(accepted, func(i, j int) bool {
+		return accepted[i].q > accepted[j].q
+	})
+
+	for _, at := range accepted {
+		for _, rd := range renderers {
+			offered, _, _ := mime.ParseMediaType(rd.ContentType())
+			if at.mediaType == offered || at.mediaType == "*/*" ||
+				(strings.HasSuffix(at.mediaType, "/*") && strings.HasPrefix(offered, strings.TrimSuffix(at.mediaType, "*"))) {
+				return rd
+			}
+		}
+	}
+	return renderers[0]
+}
@@ -8,7 +8,15 @@ import (
 )
 
 const (
-	HitsPerPageLimit = 100
+	HitsPerPageDefault = 20
+	HitsPerPageLimit   = 100
+
+	FollowingUsersLimit = 50
+	CombineFeedsLimit   = 10
+
+	// DupesLookupsLimit caps the stories /dupes checks per request, as
+	// each one costs an extra Algolia search.
+	DupesLookupsLimit = 30
 )
 
 type outputParams struct {
@@ -16,12 +24,28 @@ type outputParams struct {
 	Link        string
 	Description string `form:"description"`
 	LinkTo      string `form:"link"`
+	Transform   string `form:"transform"`
+	Version     string `form:"version"`
+	Content     string `form:"content"`
+	TitleFormat string `form:"title_format"`
+	Frontend    string `form:"frontend"`
+	Canonical   bool   `form:"canonical"`
+	Dedupe      bool   `form:"dedupe"`
 	Format      string
 	SelfLink    string
+
+	// Transformers are applied to the hits before rendering. Those
+	// belonging to the feed come first, followed by any from Transform.
+	Transformers []Transformer
+
+	// titleFromStory makes writeResults title the feed after the story
+	// the hits belong to, as /item does.
+	titleFromStory bool
 }
 
 type searchParams struct {
 	Tags             string
+	TitlePrefix      string
 	Query            string `form:"q"`
 	OptionalWords    string
 	Filters          string
@@ -30,6 +54,11 @@ type searchParams struct {
 	Comments         string `form:"comments"`
 	SearchAttributes string `form:"search_attrs"`
 	Count            string `form:"count"`
+	Lookback         string `form:"lookback"`
+	Since            string `form:"since"`
+	Users            string `form:"users"`
+	Type             string `form:"type"`
+	Highlight        bool   `form:"highlight"`
 }
 
 func (sp *searchParams) numericFilters() string {
@@ -43,15 +72,33 @@ func (sp *searchParams) numericFilters() string {
 	return strings.Join(filters, ",")
 }
 
+// hitsPerPage returns the number of items requested, bounded by HitsPerPageLimit
+func (sp *searchParams) hitsPerPage() int {
+	c, err := strconv.Atoi(sp.Count)
+	if err != nil || c < 1 {
+		c = HitsPerPageDefault
+	} else if c > HitsPerPageLimit {
+		c = HitsPerPageLimit
+	}
+	return c
+}
+
 // Encode transforms the search options into an Algolia search querystring
 func (sp *searchParams) Values() url.Values {
 	params := make(url.Values)
 
+	var query []string
+	if sp.TitlePrefix != "" {
+		query = append(query, fmt.Sprintf("\"%s\"", strings.TrimSuffix(sp.TitlePrefix, ":")))
+	}
 	if sp.OptionalWords != "" {
-		params.Set("query", sp.Query)
+		query = append(query, sp.Query)
 		params.Set("optionalWords", sp.OptionalWords)
 	} else if sp.Query != "" {
-		params.Set("query", fmt.Sprintf("\"%s\"", sp.Query))
+		query = append(query, fmt.Sprintf("\"%s\"", sp.Query))
+	}
+	if len(query) > 0 {
+		params.Set("query", strings.Join(query, " "))
 	}
 
 	if f := sp.numericFilters(); f != "" {
@@ -67,13 +114,7 @@ func (sp *searchParams) Values() url.Values {
 	}
 
 	if sp.Count != "" {
-		c, err := strconv.Atoi(sp.Count)
-		if err != nil {
-			c = 20
-		} else if c > HitsPerPageLimit {
-			c = HitsPerPageLimit
-		}
-		params.Set("hitsPerPage", strconv.Itoa(c))
+		params.Set("hitsPerPage", strconv.Itoa(sp.hitsPerPage()))
 	}
 
 	if sp.Filters != "" {
@@ -84,5 +125,11 @@ func (sp *searchParams) Values() url.Values {
 		params.Set("tags", sp.Tags)
 	}
 
+	if sp.Highlight {
+		params.Set("highlightPreTag", highlightPreTag)
+		params.Set("highlightPostTag", highlightPostTag)
+		params.Set("attributesToSnippet", "comment_text:"+snippetWords+",story_text:"+snippetWords)
+	}
+
 	return params
 }
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// pollsConcurrency bounds how many polls have their options fetched at
+// once for a single feed.
+const pollsConcurrency = 5
+
+// pollOptionsCache holds just the options of each poll, rather than the
+// full item tree they arrive with.
+var pollOptionsCache = newTTLCache("polls", 5*time.Minute, cacheMaxEntries)
+
+// loadPollOptions fetches the options of any polls in results so they can
+// be included in the item descriptions. Polls whose options can't be
+// fetched are left as-is.
+func loadPollOptions(ctx context.Context, results *AlgoliaSearchResponse) {
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, pollsConcurrency)
+	)
+	for i := range results.Hits {
+		hit := &results.Hits[i]
+		if !hit.isPoll() {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			options, err := pollOptions(ctx, hit.ObjectID)
+			if err != nil {
+				return
+			}
+			hit.PollOptions = options
+		}()
+	}
+	wg.Wait()
+}
+
+// pollOptions returns the sanitized options of the poll with the given ID.
+// The result is shared between callers, so it must not be modified.
+func pollOptions(ctx context.Context, id string) ([]PollOption, error) {
+	if cached, ok := pollOptionsCache.Get(id); ok {
+		return cached.([]PollOption), nil
+	}
+
+	item, err := fetchItem(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	options := make([]PollOption, len(item.Options))
+	for j, option := range item.Options {
+		options[j] = PollOption{
+			Text:   sanitizeHTML(option.Text),
+			Points: option.Points,
+		}
+	}
+	pollOptionsCache.Set(id, options)
+	return options, nil
+}
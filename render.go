@@ -1,18 +1,88 @@
 package main
 
 import (
+	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"io"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
-func renderResults(c *gin.Context, sp *searchParams, op *outputParams) {
-	if op.Format == "" {
-		op.Format = "rss"
+// Renderer turns search results into a feed document in a single format.
+type Renderer interface {
+	// Name is the format name stored in the request context by SetFormat.
+	Name() string
+	// Extension is the suffix, without the dot, of the routes serving
+	// this format.
+	Extension() string
+	ContentType() string
+	Render(w io.Writer, results *AlgoliaSearchResponse, op *outputParams) error
+}
+
+// renderers holds every available format. The first is the default.
+var renderers = []Renderer{
+	feedRenderer{"rss", "rss", "application/rss+xml; charset=utf-8", xmlDocument(func(results *AlgoliaSearchResponse, op *outputParams) interface{} {
+		return resultsAsRSS(results, op)
+	})},
+	feedRenderer{"atom", "atom", "application/atom+xml; charset=utf-8", xmlDocument(func(results *AlgoliaSearchResponse, op *outputParams) interface{} {
+		return resultsAsAtom(results, op)
+	})},
+	feedRenderer{"jsonfeed", "jsonfeed", "application/feed+json; charset=utf-8", jsonDocument(func(results *AlgoliaSearchResponse, op *outputParams) interface{} {
+		// .jsonfeed stays on 1.0 for existing subscribers unless
+		// they opt in to 1.1.
+		if op.Version == "1.1" {
+			return resultsAsJSONFeed11(results, op)
+		}
+		return resultsAsJSONFeed(results, op)
+	})},
+	feedRenderer{"json", "json", "application/feed+json; charset=utf-8", jsonDocument(func(results *AlgoliaSearchResponse, op *outputParams) interface{} {
+		return resultsAsJSONFeed11(results, op)
+	})},
+	feedRenderer{"text", "txt", "text/plain; charset=utf-8", resultsAsText},
+	feedRenderer{"markdown", "md", "text/markdown; charset=utf-8", resultsAsMarkdown},
+}
+
+func rendererFor(name string) Renderer {
+	for _, rd := range renderers {
+		if rd.Name() == name {
+			return rd
+		}
 	}
+	return nil
+}
+
+type renderFunc func(w io.Writer, results *AlgoliaSearchResponse, op *outputParams) error
+
+// feedRenderer is a Renderer built from its parts.
+type feedRenderer struct {
+	name, extension, contentType string
+	render                       renderFunc
+}
+
+func (rd feedRenderer) Name() string        { return rd.name }
+func (rd feedRenderer) Extension() string   { return rd.extension }
+func (rd feedRenderer) ContentType() string { return rd.contentType }
 
-	results, err := GetResults(sp.Values())
+func (rd feedRenderer) Render(w io.Writer, results *AlgoliaSearchResponse, op *outputParams) error {
+	return rd.render(w, results, op)
+}
+
+func xmlDocument(build func(*AlgoliaSearchResponse, *outputParams) interface{}) renderFunc {
+	return func(w io.Writer, results *AlgoliaSearchResponse, op *outputParams) error {
+		return xml.NewEncoder(w).Encode(build(results, op))
+	}
+}
+
+func jsonDocument(build func(*AlgoliaSearchResponse, *outputParams) interface{}) renderFunc {
+	return func(w io.Writer, results *AlgoliaSearchResponse, op *outputParams) error {
+		return json.NewEncoder(w).Encode(build(results, op))
+	}
+}
+
+func renderResults(c *gin.Context, sp *searchParams, op *outputParams) {
+	results, err := GetFeedResults(c.Request.Context(), sp.Values())
 	if err != nil {
 		c.Error(err)
 		c.String(http.StatusBadGateway, err.Error())
@@ -20,13 +90,35 @@ func renderResults(c *gin.Context, sp *searchParams, op *outputParams) {
 	}
 	c.Header("X-Algolia-URL", algoliaSearchURL+sp.Values().Encode())
 
+	writeResults(c, results, sp, op)
+}
+
+// writeResults renders results that have already been fetched from Algolia.
+func writeResults(c *gin.Context, results *AlgoliaSearchResponse, sp *searchParams, op *outputParams) {
+	if op.Format == "" {
+		op.Format = "rss"
+	}
+
+	results.Hits = applyTransformers(results.Hits, op.Transformers)
+	if sp.Highlight {
+		applyHighlights(results.Hits)
+	} else {
+		clearHighlights(results.Hits)
+	}
+	loadPollOptions(c.Request.Context(), results)
+	hitsPerResponse.Observe(float64(len(results.Hits)))
+
+	if results.Stale {
+		c.Header("Warning", `110 - "Response is Stale"`)
+	}
+
 	if len(results.Hits) > 0 {
 		item := results.Hits[0]
 
 		recent := item.GetCreatedAt()
 		c.Header("Last-Modified", Timestamp("http", recent))
 
-		if c.Request.URL.Path == "/item" {
+		if op.titleFromStory {
 			if sp.Query != "" {
 				op.Title = fmt.Sprintf("Hacker News - \"%s\": \"%s\"", item.StoryTitle, sp.Query)
 			} else {
@@ -35,12 +127,13 @@ func renderResults(c *gin.Context, sp *searchParams, op *outputParams) {
 		}
 	}
 
-	switch op.Format {
-	case "rss":
-		c.XML(http.StatusOK, resultsAsRSS(results, op))
-	case "atom":
-		c.XML(http.StatusOK, resultsAsAtom(results, op))
-	case "jsonfeed":
-		c.JSON(http.StatusOK, resultsAsJSONFeed(results, op))
+	rd := rendererFor(op.Format)
+	if rd == nil {
+		rd = renderers[0]
+	}
+	c.Header("Content-Type", rd.ContentType())
+	c.Status(http.StatusOK)
+	if err := rd.Render(c.Writer, results, op); err != nil {
+		c.Error(err)
 	}
 }
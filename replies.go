@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// RepliesLookbackDefault is how many of a user's own items are
+	// checked for replies when no lookback is given.
+	RepliesLookbackDefault = 100
+	RepliesLookbackLimit   = 1000
+
+	// repliesChunkSize bounds the number of parent_id clauses per
+	// Algolia query so the filter string stays reasonably sized.
+	repliesChunkSize = 50
+	repliesPageSize  = 500
+
+	// repliesConcurrency bounds how many chunks are searched at once for
+	// a single feed.
+	repliesConcurrency = 4
+)
+
+// lookback returns how many of the user's own items to search for replies.
+func (sp *searchParams) lookback() int {
+	n, err := strconv.Atoi(sp.Lookback)
+	if err != nil || n < 1 {
+		return RepliesLookbackDefault
+	} else if n > RepliesLookbackLimit {
+		return RepliesLookbackLimit
+	}
+	return n
+}
+
+// parseWindow parses a time window such as "36h" or "7d".
+func parseWindow(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, errors.New("invalid since window: " + s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, errors.New("invalid since window: " + s)
+	}
+	return d, nil
+}
+
+// fetchUserItemIDs returns the IDs of the author's most recent stories,
+// polls and comments, newest first.
+func fetchUserItemIDs(ctx context.Context, author string, limit int, since time.Duration) ([]string, error) {
+	var ids []string
+
+	for page := 0; len(ids) < limit; page++ {
+		params := make(url.Values)
+		params.Set("tags", "(story,comment,poll),author_"+author)
+		params.Set("hitsPerPage", strconv.Itoa(repliesPageSize))
+		params.Set("page", strconv.Itoa(page))
+		if since > 0 {
+			params.Set("numericFilters", "created_at_i>"+strconv.FormatInt(UTCNow().Add(-since).Unix(), 10))
+		}
+
+		results, err := GetResults(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		for _, hit := range results.Hits {
+			ids = append(ids, hit.ObjectID)
+		}
+		if len(results.Hits) < repliesPageSize {
+			break
+		}
+	}
+
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids, nil
+}
+
+// fetchReplies finds the newest comments whose parent is one of parentIDs.
+// The parents are split into chunks that are queried concurrently and the
+// results merged newest first.
+func fetchReplies(ctx context.Context, sp *searchParams, parentIDs []string) (*AlgoliaSearchResponse, error) {
+	var chunks [][]string
+	for len(parentIDs) > 0 {
+		n := repliesChunkSize
+		if n > len(parentIDs) {
+			n = len(parentIDs)
+		}
+		chunks = append(chunks, parentIDs[:n])
+		parentIDs = parentIDs[n:]
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		merged  AlgoliaSearchResponse
+		lastErr error
+		sem     = make(chan struct{}, repliesConcurrency)
+	)
+	for _, chunk := range chunks {
+		filters := make([]string, len(chunk))
+		for i, id := range chunk {
+			filters[i] = "parent_id=" + id
+		}
+
+		csp := *sp
+		csp.Filters = strings.Join(filters, " OR ")
+		csp.Count = strconv.Itoa(sp.hitsPerPage())
+
+		wg.Add(1)
+		go func(params url.Values) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results, err := GetResults(ctx, params)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				lastErr = err
+				return
+			}
+			merged.Hits = append(merged.Hits, results.Hits...)
+		}(csp.Values())
+	}
+	wg.Wait()
+
+	if lastErr != nil {
+		return nil, lastErr
+	}
+
+	sort.SliceStable(merged.Hits, func(i, j int) bool {
+		return merged.Hits[i].CreatedAtI > merged.Hits[j].CreatedAtI
+	})
+	if limit := sp.hitsPerPage(); len(merged.Hits) > limit {
+		merged.Hits = merged.Hits[:limit]
+	}
+	return &merged, nil
+}
@@ -6,6 +6,8 @@ type RSS struct {
 	Version       string    `xml:"version,attr"`
 	NSDublinCore  string    `xml:"xmlns:dc,attr"`
 	NSAtom        string    `xml:"xmlns:atom,attr"`
+	NSSlash       string    `xml:"xmlns:slash,attr"`
+	NSWellFormed  string    `xml:"xmlns:wfw,attr"`
 	Title         string    `xml:"channel>title"`
 	Link          string    `xml:"channel>link"`
 	Description   string    `xml:"channel>description"`
@@ -29,6 +31,10 @@ type RSSItem struct {
 	Author      string       `xml:"dc:creator"`
 	Comments    string       `xml:"comments"`
 	Permalink   RSSPermalink `xml:"guid"`
+	NumComments *int         `xml:"slash:comments,omitempty"`
+	CommentRSS  string       `xml:"wfw:commentRss"`
+	Categories  []string     `xml:"category"`
+	Related     *AtomLink    `xml:"atom:link,omitempty"`
 }
 
 // func NewRSS(results *AlgoliaSearchResponse, op *outputParams) *RSS {
@@ -37,6 +43,8 @@ func resultsAsRSS(results *AlgoliaSearchResponse, op *outputParams) *RSS {
 		Version:       "2.0",
 		NSAtom:        NSAtom,
 		NSDublinCore:  NSDublinCore,
+		NSSlash:       NSSlash,
+		NSWellFormed:  NSWellFormed,
 		Title:         op.Title,
 		Link:          op.Link,
 		Description:   "Hacker News RSS",
@@ -49,13 +57,25 @@ func resultsAsRSS(results *AlgoliaSearchResponse, op *outputParams) *RSS {
 
 	for i, hit := range results.Hits {
 		item := RSSItem{
-			Title:       CDATA{hit.GetTitle()},
-			Link:        hit.GetURL(op.LinkTo),
-			Description: CDATA{hit.GetDescription()},
+			Title:       CDATA{hit.FormatTitle(op.TitleFormat)},
+			Link:        hit.GetURL(op.LinkTo, op.Frontend),
+			Description: CDATA{convertContent(hit.GetDescription(op.Description), op.Content)},
 			Author:      hit.Author,
-			Comments:    hit.GetPermalink(),
+			Comments:    hit.GetDiscussionURL(op.Frontend),
 			Published:   Timestamp("rss", hit.GetCreatedAt()),
 			Permalink:   RSSPermalink{hit.GetPermalink(), "false"},
+			CommentRSS:  hit.GetCommentFeed(""),
+			Categories:  hit.GetTags(),
+		}
+		if op.LinkTo == "both" {
+			item.Related = &AtomLink{hit.GetDiscussionURL(op.Frontend), "related", "text/html"}
+		}
+		if !hit.isComment() {
+			numComments := hit.NumComments
+			item.NumComments = &numComments
+		}
+		if domain := hit.GetDomain(); domain != "" {
+			item.Categories = append(item.Categories, domain)
 		}
 		rss.Items[i] = item
 	}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// allowedTags is the set of elements that survive sanitization. HN only
+// ever emits a handful of these, so anything else is treated as hostile.
+var allowedTags = map[string]bool{
+	"p":          true,
+	"a":          true,
+	"i":          true,
+	"em":         true,
+	"b":          true,
+	"strong":     true,
+	"code":       true,
+	"pre":        true,
+	"blockquote": true,
+	"br":         true,
+	"mark":       true,
+}
+
+// droppedTags are elements whose contents are discarded along with the tag.
+// Void elements such as embed have no contents or end tag, so they're left
+// out; the allowlist drops them.
+var droppedTags = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"template": true,
+	"iframe":   true,
+	"object":   true,
+	"svg":      true,
+	"math":     true,
+	"noscript": true,
+	"textarea": true,
+	"title":    true,
+	"xmp":      true,
+	"noembed":  true,
+	"noframes": true,
+}
+
+// isSafeURL reports whether href can't run script when followed. Only
+// http, https and mailto links, and plain relative paths, are allowed.
+func isSafeURL(href string) bool {
+	u, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "mailto":
+		return true
+	case "":
+		return u.Host == "" && u.Path != "" && !strings.Contains(u.Path, ":")
+	}
+	return false
+}
+
+// sanitizeHTML reduces user-supplied HTML to the small allowlist of
+// elements HN itself produces. Every attribute other than a link's href is
+// dropped, links are marked rel="nofollow", and anything that isn't markup
+// (comments, doctypes, processing instructions) is discarded. The input is
+// expected to be HTML already: entities are decoded by the tokenizer and
+// re-escaped on output, so escaped markup never becomes live.
+func sanitizeHTML(s string) string {
+	var (
+		b    strings.Builder
+		z    = html.NewTokenizer(strings.NewReader(s))
+		skip = 0
+		open []string
+	)
+
+	// closeTag closes everything opened since the matching start tag so
+	// the output is always well nested.
+	closeTag := func(name string) {
+		for i := len(open) - 1; i >= 0; i-- {
+			if open[i] == name {
+				for _, tag := range reverse(open[i:]) {
+					b.WriteString("</" + tag + ">")
+				}
+				open = open[:i]
+				return
+			}
+		}
+	}
+
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if z.Err() != io.EOF {
+				return ""
+			}
+			break
+		}
+
+		tok := z.Token()
+		switch tt {
+		case html.TextToken:
+			if skip == 0 {
+				b.WriteString(html.EscapeString(tok.Data))
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if droppedTags[tok.Data] {
+				if tt == html.StartTagToken {
+					skip++
+				}
+				continue
+			}
+			if skip > 0 || !allowedTags[tok.Data] {
+				continue
+			}
+			if tok.Data == "p" {
+				// HN separates paragraphs with bare <p> tags.
+				closeTag("p")
+			}
+			tok.Attr = sanitizeAttrs(tok)
+			b.WriteString(tok.String())
+			if tt == html.StartTagToken && tok.Data != "br" {
+				open = append(open, tok.Data)
+			}
+		case html.EndTagToken:
+			if droppedTags[tok.Data] {
+				if skip > 0 {
+					skip--
+				}
+				continue
+			}
+			if skip == 0 {
+				closeTag(tok.Data)
+			}
+		}
+	}
+
+	// Close anything left dangling so the fragment can't swallow
+	// markup that follows it.
+	for _, tag := range reverse(open) {
+		b.WriteString("</" + tag + ">")
+	}
+
+	return b.String()
+}
+
+func sanitizeAttrs(tok html.Token) []html.Attribute {
+	if tok.Data != "a" {
+		return nil
+	}
+	var attrs []html.Attribute
+	for _, attr := range tok.Attr {
+		if attr.Namespace == "" && attr.Key == "href" && isSafeURL(attr.Val) {
+			attrs = append(attrs, html.Attribute{Key: "href", Val: attr.Val})
+			break
+		}
+	}
+	return append(attrs, html.Attribute{Key: "rel", Val: "nofollow"})
+}
+
+func reverse(tags []string) []string {
+	rv := make([]string, len(tags))
+	for i, tag := range tags {
+		rv[len(tags)-1-i] = tag
+	}
+	return rv
+}
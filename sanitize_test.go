@@ -0,0 +1,69 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func FuzzSanitizeHTML(f *testing.F) {
+	for _, seed := range []string{
+		"",
+		"plain text",
+		"Hi &amp; <i>there</i><p>See <a href=\"https://example.com/\">https://example.com/</a>",
+		"<pre><code>x := 1</code></pre>",
+		"<script>alert(1)</script>after",
+		"<a href=\"javascript:alert(1)\">x</a>",
+		"<a href=\"/item?id=1\" onclick=\"x()\">x</a>",
+		"<b><i>unclosed",
+		"a<embed src=x>b<p>rest of comment",
+		"<svg><script>x</script></svg>tail",
+		"</script>stray<p>end",
+		"<mark>match</mark>",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		out := sanitizeHTML(s)
+
+		z := html.NewTokenizer(strings.NewReader(out))
+		for {
+			tt := z.Next()
+			if tt == html.ErrorToken {
+				if z.Err() != io.EOF {
+					t.Fatalf("sanitizeHTML(%q) = %q, which doesn't tokenize: %v", s, out, z.Err())
+				}
+				break
+			}
+			tok := z.Token()
+			if tt != html.StartTagToken && tt != html.EndTagToken && tt != html.SelfClosingTagToken {
+				continue
+			}
+			if !allowedTags[tok.Data] {
+				t.Fatalf("sanitizeHTML(%q) = %q, which contains <%s>", s, out, tok.Data)
+			}
+			for _, attr := range tok.Attr {
+				switch {
+				case tok.Data == "a" && attr.Key == "rel":
+				case tok.Data == "a" && attr.Key == "href" && isSafeURL(attr.Val):
+				default:
+					t.Fatalf("sanitizeHTML(%q) = %q, which has %s=%q on <%s>", s, out, attr.Key, attr.Val, tok.Data)
+				}
+			}
+		}
+
+		if again := sanitizeHTML(out); again != out {
+			t.Fatalf("sanitizeHTML isn't stable for %q: %q then %q", s, out, again)
+		}
+	})
+}
+
+func TestSanitizeHTMLVoidElements(t *testing.T) {
+	got := sanitizeHTML("a<embed src=x>b<p>rest of comment")
+	if want := "ab<p>rest of comment</p>"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	bolt "go.etcd.io/bbolt"
+)
+
+var savedBucket = []byte("saved")
+
+const (
+	// savedMaxBodyBytes caps the size of a POST /saved request body.
+	savedMaxBodyBytes = 16 << 10
+
+	// savedSearchesLimit caps how many saved searches are stored.
+	savedSearchesLimit = 10000
+)
+
+var errSavedFull = errors.New("too many saved searches")
+
+// savedSearches is nil unless the server was started with -db.
+var savedSearches *savedStore
+
+// savedSearch is a feed endpoint and its query parameters, stored under a
+// short slug.
+type savedSearch struct {
+	Slug     string     `json:"slug"`
+	Endpoint string     `json:"endpoint"`
+	Params   url.Values `json:"params"`
+	Created  string     `json:"created"`
+}
+
+// savedStore keeps saved searches in an on-disk bolt database.
+type savedStore struct {
+	db *bolt.DB
+}
+
+func openSavedStore(path string) (*savedStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(savedBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &savedStore{db}, nil
+}
+
+func (s *savedStore) Close() error {
+	return s.db.Close()
+}
+
+func newSlug() (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Put stores ss under a newly generated slug. It returns errSavedFull once
+// savedSearchesLimit searches are stored.
+func (s *savedStore) Put(ss *savedSearch) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(savedBucket)
+		if b.Stats().KeyN >= savedSearchesLimit {
+			return errSavedFull
+		}
+		for {
+			slug, err := newSlug()
+			if err != nil {
+				return err
+			}
+			if b.Get([]byte(slug)) == nil {
+				ss.Slug = slug
+				break
+			}
+		}
+
+		data, err := json.Marshal(ss)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(ss.Slug), data)
+	})
+}
+
+// Get returns the saved search stored under slug, or nil if there isn't one.
+func (s *savedStore) Get(slug string) (*savedSearch, error) {
+	var ss *savedSearch
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(savedBucket).Get([]byte(slug))
+		if data == nil {
+			return nil
+		}
+		ss = new(savedSearch)
+		return json.Unmarshal(data, ss)
+	})
+	return ss, err
+}
+
+func (s *savedStore) List() ([]savedSearch, error) {
+	searches := []savedSearch{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(savedBucket).ForEach(func(k, v []byte) error {
+			var ss savedSearch
+			if err := json.Unmarshal(v, &ss); err != nil {
+				return err
+			}
+			searches = append(searches, ss)
+			return nil
+		})
+	})
+	return searches, err
+}
+
+// Delete removes the saved search stored under slug, reporting whether
+// there was one.
+func (s *savedStore) Delete(slug string) (bool, error) {
+	var found bool
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(savedBucket)
+		found = b.Get([]byte(slug)) != nil
+		return b.Delete([]byte(slug))
+	})
+	return found, err
+}
+
+// RequireAdmin rejects requests without a bearer token matching token.
+func RequireAdmin(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		given := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if token == "" || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	}
+}
+
+// createSavedHandler stores a feed endpoint and its parameters, e.g.
+// {"endpoint": "/newest", "params": {"q": ["golang"]}}. The endpoint may
+// also carry its own query string.
+func createSavedHandler(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, savedMaxBodyBytes)
+
+	var ss savedSearch
+	if err := c.ShouldBindJSON(&ss); err != nil {
+		c.Error(err)
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	u, err := url.Parse(ss.Endpoint)
+	if err != nil || u.IsAbs() {
+		c.String(http.StatusBadRequest, "endpoint must be a feed path such as /newest")
+		return
+	}
+	if _, ok := endpoints[u.Path]; !ok {
+		c.String(http.StatusBadRequest, "unknown endpoint: "+u.Path)
+		return
+	}
+
+	params := u.Query()
+	for k, vs := range ss.Params {
+		for _, v := range vs {
+			params.Add(k, v)
+		}
+	}
+
+	if err := bindParams(params, new(searchParams), new(outputParams)); err != nil {
+		c.Error(err)
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ss.Endpoint = u.Path
+	ss.Params = params
+	ss.Created = Timestamp("jsonfeed", UTCNow())
+	if err := savedSearches.Put(&ss); err == errSavedFull {
+		c.String(http.StatusInsufficientStorage, err.Error())
+		return
+	} else if err != nil {
+		c.Error(err)
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"slug": ss.Slug,
+		"url":  SiteURL + "/s/" + ss.Slug,
+	})
+}
+
+// savedFeedHandler renders a saved search. The slug may carry a format
+// extension such as .atom, otherwise the format is negotiated.
+func savedFeedHandler(c *gin.Context) {
+	slug, format := c.Param("slug"), ""
+	for _, rd := range renderers {
+		if ext := "." + rd.Extension(); strings.HasSuffix(slug, ext) {
+			slug, format = strings.TrimSuffix(slug, ext), rd.Name()
+			break
+		}
+	}
+	if format == "" {
+		c.Header("Vary", "Accept")
+		format = negotiateRenderer(c.GetHeader("Accept")).Name()
+	}
+
+	ss, err := savedSearches.Get(slug)
+	if err != nil {
+		c.Error(err)
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	if ss == nil {
+		c.String(http.StatusNotFound, "no saved search "+slug)
+		return
+	}
+	fn, ok := endpoints[ss.Endpoint]
+	if !ok {
+		c.String(http.StatusNotFound, "saved search endpoint no longer exists: "+ss.Endpoint)
+		return
+	}
+
+	c.Request.URL.RawQuery = ss.Params.Encode()
+	c.Set("format", format)
+	fn(c)
+}
+
+func listSavedHandler(c *gin.Context) {
+	searches, err := savedSearches.List()
+	if err != nil {
+		c.Error(err)
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, searches)
+}
+
+func deleteSavedHandler(c *gin.Context) {
+	found, err := savedSearches.Delete(c.Param("slug"))
+	if err != nil {
+		c.Error(err)
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !found {
+		c.String(http.StatusNotFound, "no saved search "+c.Param("slug"))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
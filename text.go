@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// resultsAsText renders results as a plain-text document.
+func resultsAsText(w io.Writer, results *AlgoliaSearchResponse, op *outputParams) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n%s\n", op.Title, op.Link)
+
+	for _, hit := range results.Hits {
+		b.WriteString("\n" + strings.Repeat("-", 72) + "\n\n")
+		fmt.Fprintf(&b, "%s\n%s\n", hit.FormatTitle(op.TitleFormat), hit.GetURL(op.LinkTo, op.Frontend))
+		fmt.Fprintf(&b, "by %s at %s | %s\n", hit.Author, Timestamp("jsonfeed", hit.GetCreatedAt()), hit.GetDiscussionURL(op.Frontend))
+		if text := htmlToText(hit.GetDescription(op.Description)); text != "" {
+			b.WriteString("\n" + text + "\n")
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// resultsAsMarkdown renders results as a Markdown document.
+func resultsAsMarkdown(w io.Writer, results *AlgoliaSearchResponse, op *outputParams) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# [%s](%s)\n", markdownEscaper.Replace(op.Title), markdownURLEscaper.Replace(op.Link))
+
+	for _, hit := range results.Hits {
+		fmt.Fprintf(&b, "\n## [%s](%s)\n\n", markdownEscaper.Replace(hit.FormatTitle(op.TitleFormat)), markdownURLEscaper.Replace(hit.GetURL(op.LinkTo, op.Frontend)))
+		fmt.Fprintf(&b, "by [%s](%s) at %s | [discuss](%s)\n", markdownEscaper.Replace(hit.Author), hit.GetAuthorURL(),
+			Timestamp("jsonfeed", hit.GetCreatedAt()), hit.GetDiscussionURL(op.Frontend))
+		if text := htmlToMarkdown(hit.GetDescription(op.Description)); text != "" {
+			b.WriteString("\n" + text + "\n")
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
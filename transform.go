@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Transformer post-processes hits after they're fetched from Algolia and
+// before they're rendered. Transformers may filter, reorder or rewrite hits.
+type Transformer interface {
+	Transform(hits []AlgoliaSearchHit) []AlgoliaSearchHit
+}
+
+// TransformerFunc adapts an ordinary function to the Transformer interface.
+type TransformerFunc func(hits []AlgoliaSearchHit) []AlgoliaSearchHit
+
+func (fn TransformerFunc) Transform(hits []AlgoliaSearchHit) []AlgoliaSearchHit {
+	return fn(hits)
+}
+
+// filterHits returns a Transformer keeping only the hits keep returns true for.
+func filterHits(keep func(hit AlgoliaSearchHit) bool) Transformer {
+	return TransformerFunc(func(hits []AlgoliaSearchHit) []AlgoliaSearchHit {
+		rv := hits[:0]
+		for _, hit := range hits {
+			if keep(hit) {
+				rv = append(rv, hit)
+			}
+		}
+		return rv
+	})
+}
+
+// transformers are the built-in transformers selectable by name, either
+// per request with transform=a,b or per feed definition.
+var transformers = map[string]Transformer{
+	"ids":    TransformerFunc(dedupeHits),
+	"dedupe": TransformerFunc(dedupeURLs),
+	"links": filterHits(func(hit AlgoliaSearchHit) bool {
+		return hit.URL != ""
+	}),
+	"selfposts": filterHits(func(hit AlgoliaSearchHit) bool {
+		return !hit.isComment() && hit.URL == ""
+	}),
+	"stories": filterHits(func(hit AlgoliaSearchHit) bool {
+		return !hit.isComment()
+	}),
+	"comments":  filterHits(AlgoliaSearchHit.isComment),
+	"https":     TransformerFunc(httpsLinks),
+	"canonical": TransformerFunc(canonicalLinks),
+}
+
+// dedupeHits drops any hit whose ObjectID has already been seen.
+func dedupeHits(hits []AlgoliaSearchHit) []AlgoliaSearchHit {
+	seen := make(map[string]bool)
+	return filterHits(func(hit AlgoliaSearchHit) bool {
+		if seen[hit.ObjectID] {
+			return false
+		}
+		seen[hit.ObjectID] = true
+		return true
+	}).Transform(hits)
+}
+
+// httpsLinks rewrites plain http article links to https.
+func httpsLinks(hits []AlgoliaSearchHit) []AlgoliaSearchHit {
+	for i := range hits {
+		if strings.HasPrefix(hits[i].URL, "http://") {
+			hits[i].URL = "https://" + strings.TrimPrefix(hits[i].URL, "http://")
+		}
+	}
+	return hits
+}
+
+// titlePrefix keeps hits whose titles start with prefix. The Algolia query
+// only guarantees the prefix appears somewhere in the title.
+func titlePrefix(prefix string) Transformer {
+	return filterHits(func(hit AlgoliaSearchHit) bool {
+		return strings.HasPrefix(hit.Title, prefix)
+	})
+}
+
+// parseTransformers looks up a comma-separated list of transformer names.
+func parseTransformers(names string) ([]Transformer, error) {
+	var rv []Transformer
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		t, ok := transformers[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown transform %q", name)
+		}
+		rv = append(rv, t)
+	}
+	return rv, nil
+}
+
+func applyTransformers(hits []AlgoliaSearchHit, pipeline []Transformer) []AlgoliaSearchHit {
+	for _, t := range pipeline {
+		hits = t.Transform(hits)
+	}
+	return hits
+}
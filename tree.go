@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// discussionComment is a single comment within a discussionTree.
+type discussionComment struct {
+	ID        int                  `json:"id"`
+	Depth     int                  `json:"depth"`
+	Author    string               `json:"author"`
+	Time      string               `json:"time"`
+	Permalink string               `json:"permalink"`
+	HTML      string               `json:"html"`
+	Deleted   bool                 `json:"deleted,omitempty"`
+	Children  []*discussionComment `json:"children"`
+}
+
+// discussionTree is the full nested discussion for a story.
+type discussionTree struct {
+	ID        int                  `json:"id"`
+	Title     string               `json:"title"`
+	URL       string               `json:"url,omitempty"`
+	Author    string               `json:"author"`
+	Time      string               `json:"time"`
+	Points    int                  `json:"points"`
+	Permalink string               `json:"permalink"`
+	HTML      string               `json:"html,omitempty"`
+	Comments  []*discussionComment `json:"comments"`
+}
+
+func newDiscussionTree(item *AlgoliaItem) *discussionTree {
+	return &discussionTree{
+		ID:        item.ID,
+		Title:     item.Title,
+		URL:       item.URL,
+		Author:    item.Author,
+		Time:      Timestamp("jsonfeed", item.GetCreatedAt()),
+		Points:    item.Points,
+		Permalink: item.GetPermalink(),
+		HTML:      sanitizeHTML(item.Text),
+		Comments:  newDiscussionComments(item.Children, 0),
+	}
+}
+
+func newDiscussionComments(items []AlgoliaItem, depth int) []*discussionComment {
+	comments := make([]*discussionComment, 0, len(items))
+	for _, item := range items {
+		children := newDiscussionComments(item.Children, depth+1)
+
+		// Deleted comments are only worth keeping around for the replies
+		// hanging off them.
+		deleted := item.Author == "" && item.Text == ""
+		if deleted && len(children) == 0 {
+			continue
+		}
+
+		comments = append(comments, &discussionComment{
+			ID:        item.ID,
+			Depth:     depth,
+			Author:    item.Author,
+			Time:      Timestamp("jsonfeed", item.GetCreatedAt()),
+			Permalink: item.GetPermalink(),
+			HTML:      sanitizeHTML(item.Text),
+			Deleted:   deleted,
+			Children:  children,
+		})
+	}
+	return comments
+}
+
+var discussionHTMLTemplate = htmltemplate.Must(htmltemplate.New("tree").Funcs(htmltemplate.FuncMap{
+	"safeHTML": func(s string) htmltemplate.HTML { return htmltemplate.HTML(s) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ .Title }}</title>
+</head>
+<body>
+<h1>{{ if .URL }}<a href="{{ .URL }}">{{ .Title }}</a>{{ else }}{{ .Title }}{{ end }}</h1>
+<p>{{ .Points }} points by {{ .Author }} at <time datetime="{{ .Time }}">{{ .Time }}</time> | <a href="{{ .Permalink }}">discuss</a></p>
+{{ if .HTML }}<div>{{ safeHTML .HTML }}</div>{{ end }}
+{{ template "comments" .Comments }}
+</body>
+</html>
+{{ define "comments" }}{{ if . }}<ul>
+{{ range . }}<li id="{{ .ID }}">
+<p>{{ if .Deleted }}[deleted]{{ else }}<b>{{ .Author }}</b>{{ end }} at <a href="{{ .Permalink }}"><time datetime="{{ .Time }}">{{ .Time }}</time></a></p>
+{{ safeHTML .HTML }}
+{{ template "comments" .Children }}</li>
+{{ end }}</ul>
+{{ end }}{{ end }}`))
+
+func (tree *discussionTree) Markdown() string {
+	var b strings.Builder
+	title := markdownEscaper.Replace(tree.Title)
+	if tree.URL != "" {
+		fmt.Fprintf(&b, "# [%s](%s)\n\n", title, markdownURLEscaper.Replace(tree.URL))
+	} else {
+		fmt.Fprintf(&b, "# %s\n\n", title)
+	}
+	fmt.Fprintf(&b, "%d points by %s at %s | [discuss](%s)\n\n", tree.Points, markdownEscaper.Replace(tree.Author), tree.Time, tree.Permalink)
+	if tree.HTML != "" {
+		b.WriteString(htmlToMarkdown(tree.HTML) + "\n\n")
+	}
+	writeMarkdownComments(&b, tree.Comments)
+	return b.String()
+}
+
+func writeMarkdownComments(b *strings.Builder, comments []*discussionComment) {
+	for _, comment := range comments {
+		prefix := strings.Repeat("> ", comment.Depth)
+
+		author := "**" + markdownEscaper.Replace(comment.Author) + "**"
+		if comment.Deleted {
+			author = "[deleted]"
+		}
+		fmt.Fprintf(b, "%s%s at [%s](%s)\n%s\n", prefix, author, comment.Time, comment.Permalink, strings.TrimSpace(prefix))
+
+		for _, line := range strings.Split(htmlToMarkdown(comment.HTML), "\n") {
+			b.WriteString(strings.TrimRight(prefix+line, " ") + "\n")
+		}
+		b.WriteString("\n")
+
+		writeMarkdownComments(b, comment.Children)
+	}
+}
+
+// itemTreeHandler exports the full nested discussion of a story.
+func itemTreeHandler(c *gin.Context) {
+	id := c.Query("id")
+	if _, err := strconv.Atoi(id); err != nil {
+		c.String(http.StatusBadRequest, "id must be a numeric item ID")
+		return
+	}
+
+	item, err := GetItem(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		c.String(http.StatusBadGateway, err.Error())
+		return
+	}
+	tree := newDiscussionTree(item)
+
+	switch c.GetString("format") {
+	case "html":
+		c.Status(http.StatusOK)
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		if err := discussionHTMLTemplate.Execute(c.Writer, tree); err != nil {
+			c.Error(err)
+		}
+	case "markdown":
+		c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(tree.Markdown()))
+	default:
+		c.JSON(http.StatusOK, tree)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// upstreamAttempts bounds how many times a single fetch is tried.
+	upstreamAttempts = 3
+	backoffBase      = 200 * time.Millisecond
+	backoffMax       = 2 * time.Second
+
+	// After breakerThreshold consecutive failures requests to Algolia
+	// fail fast for breakerCooldown, then a single trial request decides
+	// whether to close the breaker again.
+	breakerThreshold = 5
+	breakerCooldown  = 30 * time.Second
+
+	// staleTTL is how long a feed's results can stand in for fresh ones
+	// while Algolia is unavailable, and staleMaxEntries how many feeds'
+	// results are kept.
+	staleTTL        = time.Hour
+	staleMaxEntries = 500
+)
+
+var errCircuitOpen = errors.New("Algolia is unavailable, try again shortly")
+
+var (
+	breaker      = &circuitBreaker{threshold: breakerThreshold, cooldown: breakerCooldown}
+	staleResults = newTTLCache("stale", staleTTL, staleMaxEntries)
+)
+
+// upstreamError is a failed request to Algolia. Reason is one of the
+// upstream* metric reasons, and Status the HTTP status if one was received.
+type upstreamError struct {
+	Reason string
+	Status int
+	Err    error
+}
+
+func (e *upstreamError) Error() string { return e.Err.Error() }
+func (e *upstreamError) Unwrap() error { return e.Err }
+
+// temporary reports whether trying again might succeed.
+func (e *upstreamError) temporary() bool {
+	switch e.Reason {
+	case upstreamTransport:
+		return true
+	case upstreamStatus:
+		return e.Status >= 500 || e.Status == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// upstreamUnavailable reports whether err means Algolia itself is having
+// trouble, as opposed to rejecting this particular request.
+func upstreamUnavailable(err error) bool {
+	var ue *upstreamError
+	if errors.As(err, &ue) {
+		return ue.temporary() || ue.Reason == upstreamDecode
+	}
+	return errors.Is(err, errCircuitOpen)
+}
+
+// backoff sleeps before retry number attempt, using full jitter on an
+// exponential delay. It returns early with an error if ctx is done.
+func backoff(ctx context.Context, attempt int) error {
+	delay := backoffBase << uint(attempt-1)
+	if delay > backoffMax {
+		delay = backoffMax
+	}
+	t := time.NewTimer(time.Duration(rand.Int63n(int64(delay)) + 1))
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// circuitBreaker stops calls to a failing upstream for a while once it
+// has failed enough times in a row.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openedAt  time.Time
+	trial     bool
+}
+
+// Allow reports whether a call may go ahead. Once the cooldown has passed
+// a single trial call is let through; its outcome must be passed to
+// Record or Release.
+func (b *circuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.trial || time.Since(b.openedAt) < b.cooldown {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// Record counts the outcome of an allowed call.
+func (b *circuitBreaker) Record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openedAt = time.Now()
+	}
+}
+
+// Release gives up an allowed call without counting it, e.g. because the
+// client went away.
+func (b *circuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+// Open reports whether calls are currently being refused.
+func (b *circuitBreaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failures >= b.threshold
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func upstreamResponse(code int, body string) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: code,
+		Status:     http.StatusText(code),
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}, nil
+}
+
+// expire makes b's cooldown run out as if it had been waited for.
+func (b *circuitBreaker) expire() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.openedAt = time.Now().Add(-b.cooldown)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	b := &circuitBreaker{threshold: 2, cooldown: time.Minute}
+
+	b.Record(true)
+	if b.Open() || !b.Allow() {
+		t.Fatal("breaker opened before reaching its threshold")
+	}
+	b.Record(true)
+	if !b.Open() || b.Allow() {
+		t.Fatal("breaker not open after reaching its threshold")
+	}
+
+	// Once the cooldown is over exactly one trial call goes ahead, and
+	// a failed trial starts the cooldown again.
+	b.expire()
+	if !b.Allow() {
+		t.Fatal("no trial call allowed after the cooldown")
+	}
+	if b.Allow() {
+		t.Fatal("second call allowed while the trial is outstanding")
+	}
+	b.Record(true)
+	if !b.Open() || b.Allow() {
+		t.Fatal("breaker let calls through after a failed trial")
+	}
+
+	// A released trial frees the slot for another one.
+	b.expire()
+	if !b.Allow() {
+		t.Fatal("no trial call allowed after the cooldown")
+	}
+	b.Release()
+	if !b.Open() {
+		t.Fatal("releasing the trial closed the breaker")
+	}
+	if !b.Allow() {
+		t.Fatal("no trial call allowed after the last one was released")
+	}
+
+	// A successful trial closes the breaker.
+	b.Record(false)
+	if b.Open() || !b.Allow() || !b.Allow() {
+		t.Fatal("breaker still open after a successful trial")
+	}
+}
+
+func TestFetchAlgoliaRetries(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		response func() (*http.Response, error)
+		calls    int
+		wantErr  bool
+		opens    bool
+	}{
+		{"ok", func() (*http.Response, error) { return upstreamResponse(http.StatusOK, `{"hits":[]}`) }, 1, false, false},
+		{"transport", func() (*http.Response, error) { return nil, errors.New("connection refused") }, upstreamAttempts, true, true},
+		{"503", func() (*http.Response, error) { return upstreamResponse(http.StatusServiceUnavailable, "") }, upstreamAttempts, true, true},
+		{"429", func() (*http.Response, error) { return upstreamResponse(http.StatusTooManyRequests, "") }, upstreamAttempts, true, true},
+		{"400", func() (*http.Response, error) { return upstreamResponse(http.StatusBadRequest, "") }, 1, true, false},
+		{"bad json", func() (*http.Response, error) { return upstreamResponse(http.StatusOK, "{") }, 1, true, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			stubAlgolia(t, func(req *http.Request) (*http.Response, error) {
+				calls++
+				return tt.response()
+			})
+			breaker.threshold = 1
+
+			var parsed AlgoliaSearchResponse
+			err := fetchAlgolia(context.Background(), "test", algoliaSearchURL, &parsed)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fetchAlgolia: err = %v", err)
+			}
+			if calls != tt.calls {
+				t.Errorf("Algolia called %d times, want %d", calls, tt.calls)
+			}
+			if breaker.Open() != tt.opens {
+				t.Errorf("breaker open = %v, want %v", breaker.Open(), tt.opens)
+			}
+		})
+	}
+}
+
+func TestFetchAlgoliaCircuitOpen(t *testing.T) {
+	calls := 0
+	stubAlgolia(t, func(req *http.Request) (*http.Response, error) {
+		calls++
+		return upstreamResponse(http.StatusServiceUnavailable, "")
+	})
+	breaker.threshold = 1
+	breaker.Record(true)
+
+	var parsed AlgoliaSearchResponse
+	if err := fetchAlgolia(context.Background(), "test", algoliaSearchURL, &parsed); !errors.Is(err, errCircuitOpen) {
+		t.Fatalf("fetchAlgolia: err = %v, want errCircuitOpen", err)
+	}
+	if calls != 0 {
+		t.Errorf("Algolia called %d times while the breaker was open", calls)
+	}
+}
+
+func TestFetchAlgoliaReleasesOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	calls := 0
+	stubAlgolia(t, func(req *http.Request) (*http.Response, error) {
+		calls++
+		cancel()
+		return nil, req.Context().Err()
+	})
+	breaker.threshold = 1
+	breaker.Record(true)
+	breaker.expire()
+
+	// The canceled call was the trial. It mustn't be retried, reopen the
+	// breaker or keep the trial slot.
+	var parsed AlgoliaSearchResponse
+	if err := fetchAlgolia(ctx, "test", algoliaSearchURL, &parsed); err == nil {
+		t.Fatal("fetchAlgolia succeeded with a canceled context")
+	}
+	if calls != 1 {
+		t.Errorf("Algolia called %d times, want 1", calls)
+	}
+	if !breaker.Allow() {
+		t.Error("trial slot not released after the client went away")
+	}
+}
@@ -1,20 +1,29 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"regexp"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
 const (
 	NSDublinCore = "http://purl.org/dc/elements/1.1/"
 	NSAtom       = "http://www.w3.org/2005/Atom"
+	NSSlash      = "http://purl.org/rss/1.0/modules/slash/"
+	NSWellFormed = "http://wellformedweb.org/CommentAPI/"
+	NSThreading  = "http://purl.org/syndication/thread/1.0"
 	SiteURL      = "https://hnrss.org"
 )
 
+var validUsername = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
 type CDATA struct {
 	Value string `xml:",cdata"`
 }
@@ -32,16 +41,26 @@ func Timestamp(fmt string, input time.Time) string {
 	}
 }
 
+// truncateText shortens s to at most n runes, ending it with an ellipsis
+// if anything was removed.
+func truncateText(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return strings.TrimSpace(string(runes[:n-1])) + "…"
+}
+
 func UTCNow() time.Time {
 	return time.Now().UTC()
 }
 
-func ParseRequest(c *gin.Context, sp *searchParams, op *outputParams) {
-	err := c.ShouldBindQuery(sp)
-	if err != nil {
-		c.Error(err)
-		c.String(http.StatusBadRequest, err.Error())
-		return
+// bindSearchParams fills sp from a querystring, the same way ParseRequest
+// does for an incoming request.
+func bindSearchParams(values url.Values, sp *searchParams) error {
+	req := &http.Request{URL: &url.URL{RawQuery: values.Encode()}}
+	if err := binding.Query.Bind(req, sp); err != nil {
+		return err
 	}
 
 	if strings.Contains(sp.Query, " OR ") {
@@ -55,13 +74,92 @@ func ParseRequest(c *gin.Context, sp *searchParams, op *outputParams) {
 		sp.Query = strings.Join(q, " ")
 		sp.OptionalWords = strings.Join(q, " ")
 	}
+	return nil
+}
 
-	err = c.ShouldBindQuery(op)
+// ParseRequest binds the search and output parameters of the request. If
+// any are invalid it responds with a 400 and returns the error, and the
+// caller should stop handling the request.
+func ParseRequest(c *gin.Context, sp *searchParams, op *outputParams) error {
+	err := parseRequest(c, sp, op)
 	if err != nil {
 		c.Error(err)
 		c.String(http.StatusBadRequest, err.Error())
-		return
+	}
+	return err
+}
+
+func parseRequest(c *gin.Context, sp *searchParams, op *outputParams) error {
+	if err := bindParams(c.Request.URL.Query(), sp, op); err != nil {
+		return err
 	}
 	op.Format = c.GetString("format")
 	op.SelfLink = SiteURL + c.Request.URL.String()
+	return nil
+}
+
+// bindParams fills and validates sp and op from a querystring.
+func bindParams(values url.Values, sp *searchParams, op *outputParams) error {
+	if err := bindSearchParams(values, sp); err != nil {
+		return err
+	}
+	req := &http.Request{URL: &url.URL{RawQuery: values.Encode()}}
+	if err := binding.Query.Bind(req, op); err != nil {
+		return err
+	}
+
+	switch op.Content {
+	case "", "html", "text", "markdown":
+	default:
+		return errors.New("content must be one of html, text or markdown")
+	}
+	if !linkTargets[op.LinkTo] {
+		return errors.New("link must be one of url, comments, archive or both")
+	}
+	if _, ok := frontends[op.Frontend]; op.Frontend != "" && !ok {
+		return fmt.Errorf("unknown front-end %q", op.Frontend)
+	}
+
+	var err error
+	op.Description, err = descriptionStyle(op.Description)
+	if err != nil {
+		return err
+	}
+	op.Transformers, err = parseTransformers(op.Transform)
+	if err != nil {
+		return err
+	}
+	if op.Canonical {
+		op.Transformers = append([]Transformer{transformers["canonical"]}, op.Transformers...)
+	}
+	if op.Dedupe {
+		op.Transformers = append(op.Transformers, transformers["dedupe"])
+	}
+	return nil
+}
+
+// parseUsers splits a comma-separated list of HN usernames, dropping
+// duplicates and rejecting anything that couldn't be a username.
+func parseUsers(s string) ([]string, error) {
+	var users []string
+	seen := make(map[string]bool)
+	for _, user := range strings.Split(s, ",") {
+		user = strings.TrimSpace(user)
+		if user == "" || seen[user] {
+			continue
+		}
+		if !validUsername.MatchString(user) {
+			return nil, fmt.Errorf("invalid username: %q", user)
+		}
+		seen[user] = true
+		users = append(users, user)
+	}
+
+	if len(users) == 0 {
+		return nil, errors.New("users must list at least one username")
+	}
+	if len(users) > FollowingUsersLimit {
+		return nil, fmt.Errorf("users is limited to %d usernames", FollowingUsersLimit)
+	}
+	return users, nil
 }
@@ -11,6 +11,12 @@ import (
 )
 
 func fetchHiring(c *gin.Context, query string) {
+	var sp searchParams
+	var op outputParams
+	if err := ParseRequest(c, &sp, &op); err != nil {
+		return
+	}
+
 	params := make(url.Values)
 	if query != "" {
 		params.Set("query", fmt.Sprintf("\"%s\"", query))
@@ -18,7 +24,7 @@ func fetchHiring(c *gin.Context, query string) {
 	}
 	params.Set("tags", "story,author_whoishiring")
 
-	results, err := GetResults(params)
+	results, err := GetResults(c.Request.Context(), params)
 	if err != nil {
 		c.Error(err)
 		c.String(http.StatusBadGateway, err.Error())
@@ -32,10 +38,6 @@ func fetchHiring(c *gin.Context, query string) {
 		return
 	}
 
-	var sp searchParams
-	var op outputParams
-	ParseRequest(c, &sp, &op)
-
 	sp.Tags = "comment"
 	if query != "" {
 		sp.Filters = "parent_id=" + results.Hits[0].ObjectID